@@ -18,6 +18,10 @@ func ActionMarkAllRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
 	db := models.GetDbSession()
 	user.LastUnreadAll = pq.NullTime{Time: time.Now(), Valid: true}
 	db.Update(user)
@@ -27,62 +31,102 @@ func ActionMarkAllRead(w http.ResponseWriter, r *http.Request) {
 
 func ActionStickThread(w http.ResponseWriter, r *http.Request) {
 	user := utils.GetCurrentUser(r)
-	if !user.CanModerate() {
+	if user == nil {
 		http.NotFound(w, r)
 		return
 	}
 
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
 	threadID, err := strconv.Atoi(r.FormValue("post_id"))
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	db := models.GetDbSession()
-	obj, err := db.Get(&models.Post{}, threadID)
-	thread := obj.(*models.Post)
+	thread, err := models.GetPost(threadID)
+	if thread == nil || err != nil || thread.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, _ := models.GetBoard(int(thread.BoardID))
+	if !models.Can(user, models.PermThreadStick, board) {
+		http.NotFound(w, r)
+		return
+	}
 
-	if thread == nil || err != nil {
+	if err := thread.SetSticky(!thread.Sticky); err != nil {
+		fmt.Printf("[error] Could not stick thread (%s)\n", err.Error())
 		http.NotFound(w, r)
 		return
 	}
 
-	thread.Sticky = !(thread.Sticky)
-	db.Update(thread)
+	action := models.ModActionThreadStick
+	if !thread.Sticky {
+		action = models.ModActionThreadUnstick
+	}
+	newModLogEntry(r, user, action).OnPost(thread).WithReason(r.FormValue("reason")).Save()
 
 	http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", thread.BoardID, thread.ID), http.StatusFound)
 }
 
 func ActionLockThread(w http.ResponseWriter, r *http.Request) {
 	user := utils.GetCurrentUser(r)
-	if !user.CanModerate() {
+	if user == nil {
 		http.NotFound(w, r)
 		return
 	}
 
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
 	threadID, err := strconv.Atoi(r.FormValue("post_id"))
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	db := models.GetDbSession()
-	obj, err := db.Get(&models.Post{}, threadID)
-	thread := obj.(*models.Post)
+	thread, err := models.GetPost(threadID)
+	if thread == nil || err != nil || thread.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
 
-	if thread == nil || err != nil {
+	board, _ := models.GetBoard(int(thread.BoardID))
+	if !models.Can(user, models.PermThreadLock, board) {
 		http.NotFound(w, r)
 		return
 	}
 
-	thread.Locked = !(thread.Locked)
-	db.Update(thread)
+	if err := thread.SetLocked(!thread.Locked); err != nil {
+		fmt.Printf("[error] Could not lock thread (%s)\n", err.Error())
+		http.NotFound(w, r)
+		return
+	}
+
+	action, event := models.ModActionThreadLock, models.WebhookThreadLock
+	if !thread.Locked {
+		action, event = models.ModActionThreadUnlock, models.WebhookThreadUnlock
+	}
+	newModLogEntry(r, user, action).OnPost(thread).WithReason(r.FormValue("reason")).Save()
+	queuePostWebhook(event, thread, user, map[string]interface{}{
+		"reason": r.FormValue("reason"),
+	})
 
 	http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", thread.BoardID, thread.ID), http.StatusFound)
 }
 
+// Moves a thread or a single post to the trash after asking for a reason
 func ActionDeleteThread(w http.ResponseWriter, r *http.Request) {
 	user := utils.GetCurrentUser(r)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
 
 	threadID, err := strconv.Atoi(r.FormValue("post_id"))
 	if err != nil {
@@ -90,29 +134,51 @@ func ActionDeleteThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := models.GetDbSession()
-	obj, err := db.Get(&models.Post{}, threadID)
-	thread := obj.(*models.Post)
+	thread, err := models.GetPost(threadID)
+	if thread == nil || err != nil || thread.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
 
-	if thread == nil || err != nil {
+	board, _ := models.GetBoard(int(thread.BoardID))
+	if board == nil || !models.Can(user, models.PermBoardRead, board) {
 		http.NotFound(w, r)
 		return
 	}
 
-	if (thread.AuthorID != user.ID) && !user.CanModerate() {
+	if (thread.AuthorID != user.ID) && !models.Can(user, models.PermPostDelete, board) {
 		http.NotFound(w, r)
 		return
 	}
 
-	redirectBoard := true
-	if thread.ParentID.Valid {
-		redirectBoard = false
+	if r.Method != "POST" {
+		utils.RenderTemplate(w, r, "action_delete_post.html", map[string]interface{}{
+			"board": board,
+			"post":  thread,
+		}, nil)
+		return
+	}
+
+	if !utils.RequireCSRF(w, r) {
+		return
+	}
+
+	reason := models.TruncateText(r.FormValue("reason"), 255)
+	err = thread.SoftDelete(user, reason)
+	if err != nil {
+		fmt.Printf("[error] Could not delete post (%s)\n", err.Error())
+		http.NotFound(w, r)
+		return
 	}
 
-	thread.DeleteAllChildren()
-	db.Delete(thread)
+	if thread.AuthorID != user.ID {
+		newModLogEntry(r, user, models.ModActionPostDelete).OnPost(thread).WithReason(reason).Save()
+	}
+	queuePostWebhook(models.WebhookPostDelete, thread, user, map[string]interface{}{
+		"reason": reason,
+	})
 
-	if redirectBoard {
+	if !thread.ParentID.Valid {
 		http.Redirect(w, r, fmt.Sprintf("/board/%d", thread.BoardID), http.StatusFound)
 	} else {
 		http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", thread.BoardID, thread.ParentID.Int64), http.StatusFound)
@@ -121,7 +187,7 @@ func ActionDeleteThread(w http.ResponseWriter, r *http.Request) {
 
 func ActionMoveThread(w http.ResponseWriter, r *http.Request) {
 	currentUser := utils.GetCurrentUser(r)
-	if currentUser == nil || !currentUser.CanModerate() {
+	if currentUser == nil {
 		http.NotFound(w, r)
 		return
 	}
@@ -130,33 +196,56 @@ func ActionMoveThread(w http.ResponseWriter, r *http.Request) {
 	boardID, err := strconv.Atoi(r.FormValue("to"))
 
 	op, err := models.GetPost(threadID)
-	boards, _ := models.GetBoards()
 
-	if op == nil || err != nil {
+	if op == nil || err != nil || op.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, err := models.GetBoard(int(op.BoardID))
+	if !models.Can(currentUser, models.PermThreadMove, board) {
 		http.NotFound(w, r)
 		return
 	}
 
-	if r.FormValue("to") != "" {
-		db := models.GetDbSession()
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
 		targetBoard, _ := models.GetBoard(boardID)
-		if targetBoard == nil {
+		if targetBoard == nil || !models.Can(currentUser, models.PermThreadMove, targetBoard) {
 			http.NotFound(w, r)
 			return
 		}
 
-		_, err := db.Exec("UPDATE posts SET board_id=$1 WHERE parent_id=$2", targetBoard.ID, op.ID)
-		op.BoardID = targetBoard.ID
-		db.Update(op)
+		err := op.MoveTo(targetBoard)
 		if err != nil {
 			http.NotFound(w, r)
 			fmt.Printf("Error moving post: %s\n", err.Error())
 			return
 		}
+
+		newModLogEntry(r, currentUser, models.ModActionThreadMove).OnPost(op).Change(
+			map[string]int64{"board_id": board.ID},
+			map[string]int64{"board_id": targetBoard.ID},
+		).WithReason(r.FormValue("reason")).Save()
+		queuePostWebhook(models.WebhookThreadMove, op, currentUser, map[string]interface{}{
+			"from_board": newWebhookBoard(board),
+			"reason":     r.FormValue("reason"),
+		})
 		http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", op.BoardID, op.ID), http.StatusFound)
+		return
 	}
 
-	board, err := models.GetBoard(int(op.BoardID))
+	// Only offer boards the thread could actually be moved to
+	var boards []*models.Board
+	readable, _ := models.GetReadableBoards(currentUser)
+	for _, target := range readable {
+		if target.ID != board.ID && models.Can(currentUser, models.PermThreadMove, target) {
+			boards = append(boards, target)
+		}
+	}
 
 	utils.RenderTemplate(w, r, "action_move_thread.html", map[string]interface{}{
 		"board":  board,
@@ -7,9 +7,29 @@ import (
 	"github.com/stevenleeg/gobb/utils"
 )
 
+var adminPages = []struct {
+	permission string
+	path       string
+}{
+	{models.PermBoardManage, "/admin/boards"},
+	{models.PermUserManage, "/admin/users"},
+	{models.PermGroupManage, "/admin/groups"},
+	{models.PermModLogView, "/admin/modlog"},
+	{models.PermWebhookManage, "/admin/webhooks"},
+	{models.PermMessageModerate, "/admin/message_reports"},
+}
+
 func Admin(w http.ResponseWriter, r *http.Request) {
 	currentUser := utils.GetCurrentUser(r)
-	if currentUser == nil || !currentUser.IsAdmin() {
+	if !models.Can(currentUser, models.PermSettingsEdit, nil) {
+		// Send people who can only use part of the admin panel there
+		for _, page := range adminPages {
+			if models.Can(currentUser, page.permission, nil) {
+				http.Redirect(w, r, page.path, http.StatusFound)
+				return
+			}
+		}
+
 		http.NotFound(w, r)
 		return
 	}
@@ -21,6 +41,16 @@ func Admin(w http.ResponseWriter, r *http.Request) {
 	current_template, _ := models.GetStringSetting("template")
 
 	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		before := map[string]string{
+			"theme_stylesheet": stylesheet,
+			"favicon_url":      favicon,
+			"template":         current_template,
+		}
+
 		stylesheet = r.FormValue("theme_stylesheet")
 		favicon = r.FormValue("favicon_url")
 		current_template = r.FormValue("template")
@@ -28,6 +58,12 @@ func Admin(w http.ResponseWriter, r *http.Request) {
 		models.SetStringSetting("favicon_url", favicon)
 		models.SetStringSetting("template", current_template)
 		success = true
+
+		newModLogEntry(r, currentUser, models.ModActionSettingsEdit).OnSettings().Change(before, map[string]string{
+			"theme_stylesheet": stylesheet,
+			"favicon_url":      favicon,
+			"template":         current_template,
+		}).Save()
 	}
 
 	utils.RenderTemplate(w, r, "admin.html", map[string]interface{}{
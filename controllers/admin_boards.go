@@ -1,20 +1,27 @@
 package controllers
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/gorilla/mux"
 	"github.com/stevenleeg/gobb/models"
 	"github.com/stevenleeg/gobb/utils"
 )
 
 func AdminBoards(w http.ResponseWriter, r *http.Request) {
 	currentUser := utils.GetCurrentUser(r)
-	if currentUser == nil || !currentUser.IsAdmin() {
+	if !models.Can(currentUser, models.PermBoardManage, nil) {
 		http.NotFound(w, r)
 		return
 	}
 
+	if r.Method == "POST" && !utils.RequireCSRF(w, r) {
+		return
+	}
+
 	db := models.GetDbSession()
 	// Creating a board
 	if r.Method == "POST" && r.FormValue("create_board") != "" {
@@ -35,7 +42,9 @@ func AdminBoards(w http.ResponseWriter, r *http.Request) {
 
 		board := models.NewBoard(name, desc, order)
 
-		db.Insert(board)
+		if err := db.Insert(board); err == nil {
+			newModLogEntry(r, currentUser, models.ModActionBoardCreate).OnBoard(board).Change(nil, getBoardLogFields(board)).Save()
+		}
 	}
 
 	// Update the boards
@@ -61,9 +70,21 @@ func AdminBoards(w http.ResponseWriter, r *http.Request) {
 			} else {
 				order = 1
 			}
-			board := models.UpdateBoard(name, desc, order, id)
+			board, _ := models.GetBoard(int(id))
+			if board == nil {
+				continue
+			}
 
+			before := getBoardLogFields(board)
+			board.Title = name
+			board.Description = desc
+			board.Order = order
 			db.Update(board)
+
+			oldValues, newValues := changedFields(before, getBoardLogFields(board))
+			if len(newValues) > 0 {
+				newModLogEntry(r, currentUser, models.ModActionBoardUpdate).OnBoard(board).Change(oldValues, newValues).Save()
+			}
 		}
 
 		if err != nil {
@@ -73,7 +94,7 @@ func AdminBoards(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete a board
-	if id := r.FormValue("delete"); id != "" {
+	if id := r.FormValue("delete"); r.Method == "POST" && id != "" {
 		obj, _ := db.Get(&models.Board{}, id)
 
 		if obj == nil {
@@ -82,7 +103,11 @@ func AdminBoards(w http.ResponseWriter, r *http.Request) {
 		}
 
 		board := obj.(*models.Board)
-		board.Delete()
+		if err := board.SoftDelete(currentUser, r.FormValue("delete_reason")); err != nil {
+			fmt.Printf("[error] Could not delete board (%s)\n", err.Error())
+		} else {
+			newModLogEntry(r, currentUser, models.ModActionBoardDelete).OnBoard(board).WithReason(board.DeleteReason).Save()
+		}
 	}
 
 	boards, _ := models.GetBoards()
@@ -91,3 +116,166 @@ func AdminBoards(w http.ResponseWriter, r *http.Request) {
 		"boards": boards,
 	}, nil)
 }
+
+// Edits who may read, start threads in and reply to a single board
+func AdminBoard(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardManage, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	board, err := models.GetBoard(id)
+	if err != nil || board == nil || board.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
+
+	groups, _ := models.GetGroups()
+
+	success := false
+	var formError string
+	if r.Method == "POST" && (r.FormValue("add_moderator") != "" || r.FormValue("remove_moderator") != "") {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if username := r.FormValue("add_moderator"); username != "" {
+			user, _ := models.GetUserByUsername(username)
+			if user == nil {
+				formError = "There's no user called " + username
+			} else if err = board.AddModerator(user); err == nil {
+				entry := newModLogEntry(r, currentUser, models.ModActionModeratorAdd).OnBoard(board)
+				entry.TargetUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+				entry.Save()
+			}
+		} else {
+			userID, _ := strconv.Atoi(r.FormValue("remove_moderator"))
+			user, _ := models.GetUser(userID)
+			if user != nil {
+				if err = board.RemoveModerator(user); err == nil {
+					entry := newModLogEntry(r, currentUser, models.ModActionModeratorRemove).OnBoard(board)
+					entry.TargetUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+					entry.Save()
+				}
+			}
+		}
+
+		if err != nil {
+			fmt.Printf("[error] Could not change board moderators (%s)\n", err.Error())
+			formError = "Could not change moderators"
+		} else if formError == "" {
+			success = true
+		}
+	} else if r.Method == "POST" && r.FormValue("update_approval") != "" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		before := getBoardApprovalLogFields(board)
+		board.ApprovePosts, _ = strconv.Atoi(r.FormValue("approve_posts"))
+		board.ApproveDays, _ = strconv.Atoi(r.FormValue("approve_days"))
+
+		if board.ApprovePosts < 0 || board.ApproveDays < 0 {
+			formError = "The approval rules can't be negative"
+		} else if _, err = models.GetDbSession().Update(board); err != nil {
+			fmt.Printf("[error] Could not save board approval rules (%s)\n", err.Error())
+			formError = "Could not save approval rules"
+		} else {
+			success = true
+			newModLogEntry(r, currentUser, models.ModActionBoardUpdate).OnBoard(board).Change(before, getBoardApprovalLogFields(board)).Save()
+		}
+	} else if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		before := getBoardAccessLogFields(board)
+		board.GuestRead = r.FormValue("guest_read") == "1"
+		board.DefaultRead = r.FormValue("default_read") == "1"
+		board.DefaultPost = r.FormValue("default_post") == "1"
+		board.DefaultReply = r.FormValue("default_reply") == "1"
+
+		var overrides []*models.BoardAccess
+		for _, group := range groups {
+			prefix := fmt.Sprintf("group_%d_", group.ID)
+			if r.FormValue(prefix+"override") != "1" {
+				continue
+			}
+
+			overrides = append(overrides, &models.BoardAccess{
+				GroupID:  group.ID,
+				CanRead:  r.FormValue(prefix+"read") == "1",
+				CanPost:  r.FormValue(prefix+"post") == "1",
+				CanReply: r.FormValue(prefix+"reply") == "1",
+			})
+		}
+
+		_, err = models.GetDbSession().Update(board)
+		if err == nil {
+			err = board.SetAccessOverrides(overrides)
+		}
+
+		if err != nil {
+			fmt.Printf("[error] Could not save board access (%s)\n", err.Error())
+			formError = "Could not save access rules"
+		} else {
+			success = true
+			newModLogEntry(r, currentUser, models.ModActionBoardAccess).OnBoard(board).Change(before, getBoardAccessLogFields(board)).Save()
+		}
+	}
+
+	overrides, _ := board.GetAccessOverrides()
+	moderators, _ := board.GetModerators()
+
+	utils.RenderTemplate(w, r, "admin_board.html", map[string]interface{}{
+		"board":      board,
+		"groups":     groups,
+		"moderators": moderators,
+		"error":      formError,
+		"success":    success,
+	}, map[string]interface{}{
+		"GetOverride": func(group *models.Group) *models.BoardAccess {
+			return overrides[group.ID]
+		},
+	})
+}
+
+// The parts of a board edited on the boards page, as recorded in the mod log
+func getBoardLogFields(board *models.Board) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       board.Title,
+		"description": board.Description,
+		"order":       board.Order,
+	}
+}
+
+// A board's approval rules, as recorded in the mod log
+func getBoardApprovalLogFields(board *models.Board) map[string]interface{} {
+	return map[string]interface{}{
+		"approve_posts": board.ApprovePosts,
+		"approve_days":  board.ApproveDays,
+	}
+}
+
+// A board's access rules, as recorded in the mod log
+func getBoardAccessLogFields(board *models.Board) map[string]interface{} {
+	fields := map[string]interface{}{
+		"guest_read":    board.GuestRead,
+		"default_read":  board.DefaultRead,
+		"default_post":  board.DefaultPost,
+		"default_reply": board.DefaultReply,
+	}
+
+	overrides, _ := board.GetAccessOverrides()
+	for groupID, access := range overrides {
+		fields[fmt.Sprintf("group_%d", groupID)] = map[string]bool{
+			"read":  access.CanRead,
+			"post":  access.CanPost,
+			"reply": access.CanReply,
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+func AdminGroups(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermGroupManage, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		group := models.NewGroup(r.FormValue("name"), r.FormValue("description"))
+		formError = group.Validate()
+		if formError == nil {
+			formError = models.GetDbSession().Insert(group)
+		}
+
+		if formError == nil {
+			newModLogEntry(r, currentUser, models.ModActionGroupCreate).OnGroup(group).Change(nil, getGroupLogFields(group)).Save()
+			http.Redirect(w, r, fmt.Sprintf("/admin/groups/%d", group.ID), http.StatusFound)
+			return
+		}
+	}
+
+	groups, err := models.GetGroups()
+	if err != nil {
+		fmt.Printf("[error] Could not get groups (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "admin_groups.html", map[string]interface{}{
+		"error":  formError,
+		"groups": groups,
+	}, nil)
+}
+
+func AdminGroup(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermGroupManage, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	group, err := models.GetGroup(id)
+	if err != nil || group == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	success := false
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		before := getGroupLogFields(group)
+		if r.FormValue("delete") != "" {
+			formError = group.Delete()
+			if formError == nil {
+				newModLogEntry(r, currentUser, models.ModActionGroupDelete).OnGroup(group).Change(before, nil).Save()
+				http.Redirect(w, r, "/admin/groups", http.StatusFound)
+				return
+			}
+		} else {
+			group.Name = r.FormValue("name")
+			group.Description = r.FormValue("description")
+			formError = group.Validate()
+
+			r.ParseForm()
+			permissions := r.Form["permissions"]
+
+			// Don't let an admin lock everybody out of the group admin
+			if formError == nil && group.ID == models.AdministratorGroupID {
+				permissions = append(permissions, models.PermGroupManage)
+			}
+
+			if formError == nil {
+				_, formError = models.GetDbSession().Update(group)
+			}
+
+			if formError == nil {
+				formError = group.SetPermissions(permissions)
+			}
+
+			success = formError == nil
+			if success {
+				newModLogEntry(r, currentUser, models.ModActionGroupUpdate).OnGroup(group).Change(before, getGroupLogFields(group)).Save()
+			}
+		}
+	}
+
+	permissions, err := models.GetPermissions()
+	if err != nil {
+		fmt.Printf("[error] Could not get permissions (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "admin_group.html", map[string]interface{}{
+		"error":       formError,
+		"success":     success,
+		"group":       group,
+		"permissions": permissions,
+	}, nil)
+}
+
+// A group and its permissions, as recorded in the mod log
+func getGroupLogFields(group *models.Group) map[string]interface{} {
+	var granted []string
+	permissions, _ := models.GetPermissions()
+	for _, permission := range permissions {
+		if group.Has(permission.Name) {
+			granted = append(granted, permission.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"name":        group.Name,
+		"description": group.Description,
+		"permissions": granted,
+	}
+}
@@ -12,7 +12,7 @@ import (
 
 func AdminUsers(w http.ResponseWriter, r *http.Request) {
 	currentUser := utils.GetCurrentUser(r)
-	if currentUser == nil || !currentUser.IsAdmin() {
+	if !models.Can(currentUser, models.PermUserManage, nil) {
 		http.NotFound(w, r)
 		return
 	}
@@ -42,7 +42,7 @@ func AdminUsers(w http.ResponseWriter, r *http.Request) {
 
 func AdminUser(w http.ResponseWriter, r *http.Request) {
 	currentUser := utils.GetCurrentUser(r)
-	if currentUser == nil || !currentUser.IsAdmin() {
+	if !models.Can(currentUser, models.PermUserManage, nil) {
 		http.NotFound(w, r)
 		return
 	}
@@ -60,7 +60,12 @@ func AdminUser(w http.ResponseWriter, r *http.Request) {
 	var form_error string
 	success := false
 	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
 		db := models.GetDbSession()
+		before := getUserLogFields(user)
 		user.Username = r.FormValue("username")
 		user.Avatar = r.FormValue("avatar_url")
 		user.UserTitle = r.FormValue("user_title")
@@ -86,6 +91,13 @@ func AdminUser(w http.ResponseWriter, r *http.Request) {
 			user.HideOnline = true
 		}
 
+		// Only allow safe URL schemes for things we embed in pages
+		if err := utils.ValidateURL(user.Avatar); err != nil {
+			form_error = "Invalid avatar URL: " + err.Error()
+		} else if err := utils.ValidateURL(user.StylesheetURL.String); err != nil {
+			form_error = "Invalid stylesheet URL: " + err.Error()
+		}
+
 		// Update the username?
 		if len(user.Username) < 3 {
 			form_error = "Username must at least 3 characters"
@@ -99,23 +111,58 @@ func AdminUser(w http.ResponseWriter, r *http.Request) {
 				form_error = "Password must be greater than 4 characters"
 			} else if new_pass != new_pass2 {
 				form_error = "Passwords didn't match"
-			} else {
-				user.SetPassword(new_pass)
+			} else if err := user.SetPassword(new_pass); err != nil {
+				form_error = "Could not set password"
 			}
 		}
 
 		group_id, _ := strconv.Atoi(r.FormValue("group_id"))
-		user.GroupID = int64(group_id)
+		if group, _ := models.GetGroup(group_id); group == nil {
+			form_error = "That group doesn't exist"
+		} else if group.ID != user.GroupID && !models.Can(currentUser, models.PermGroupManage, nil) {
+			form_error = "You aren't allowed to change this user's group"
+		} else {
+			user.GroupID = group.ID
+		}
 
 		if form_error == "" {
 			db.Update(user)
 			success = true
+
+			after := getUserLogFields(user)
+			if len(new_pass) > 0 {
+				before["password"] = ""
+				after["password"] = "changed"
+			}
+
+			oldValues, newValues := changedFields(before, after)
+			if len(newValues) > 0 {
+				newModLogEntry(r, currentUser, models.ModActionUserEdit).OnUser(user).Change(oldValues, newValues).Save()
+			}
 		}
 	}
 
+	groups, _ := models.GetGroups()
+	bans, _ := user.GetBans()
+
 	utils.RenderTemplate(w, r, "admin_user.html", map[string]interface{}{
 		"error":   form_error,
 		"success": success,
 		"user":    user,
+		"groups":  groups,
+		"bans":    bans,
 	}, nil)
 }
+
+// The parts of a user admins can change, as recorded in the mod log
+func getUserLogFields(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"username":       user.Username,
+		"avatar":         user.Avatar,
+		"user_title":     user.UserTitle,
+		"stylesheet_url": user.StylesheetURL.String,
+		"signature":      user.Signature.String,
+		"hide_online":    user.HideOnline,
+		"group_id":       user.GroupID,
+	}
+}
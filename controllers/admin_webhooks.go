@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+func AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermWebhookManage, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		secret := r.FormValue("secret")
+		if secret == "" {
+			secret = models.GenerateWebhookSecret()
+		}
+
+		r.ParseForm()
+		webhook := models.NewWebhook(currentUser, r.FormValue("url"), secret, r.Form["events"])
+		formError = webhook.Validate()
+		if formError == nil {
+			formError = models.GetDbSession().Insert(webhook)
+		}
+
+		if formError == nil {
+			newModLogEntry(r, currentUser, models.ModActionWebhookCreate).OnWebhook(webhook).Change(nil, getWebhookLogFields(webhook)).Save()
+			http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", webhook.ID), http.StatusFound)
+			return
+		}
+	}
+
+	webhooks, err := models.GetWebhooks()
+	if err != nil {
+		fmt.Printf("[error] Could not get webhooks (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "admin_webhooks.html", map[string]interface{}{
+		"error":    formError,
+		"webhooks": webhooks,
+		"events":   models.WebhookEvents,
+	}, nil)
+}
+
+func AdminWebhook(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermWebhookManage, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	webhook, err := models.GetWebhook(id)
+	if err != nil || webhook == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	success := false
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		before := getWebhookLogFields(webhook)
+		if r.FormValue("delete") != "" {
+			formError = webhook.Delete()
+			if formError == nil {
+				newModLogEntry(r, currentUser, models.ModActionWebhookDelete).OnWebhook(webhook).Change(before, nil).Save()
+				http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+				return
+			}
+		} else if deliveryID, _ := strconv.Atoi(r.FormValue("redeliver")); deliveryID != 0 {
+			delivery, _ := models.GetWebhookDelivery(deliveryID)
+			if delivery == nil || delivery.WebhookID != webhook.ID {
+				http.NotFound(w, r)
+				return
+			}
+
+			formError = delivery.Retry()
+			if formError == nil {
+				http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", webhook.ID), http.StatusFound)
+				return
+			}
+		} else {
+			r.ParseForm()
+			oldSecret := webhook.Secret
+			webhook.URL = r.FormValue("url")
+			webhook.Secret = r.FormValue("secret")
+			webhook.Active = r.FormValue("active") == "1"
+			webhook.SetEvents(r.Form["events"])
+
+			formError = webhook.Validate()
+			if formError == nil {
+				_, formError = models.GetDbSession().Update(webhook)
+			}
+
+			success = formError == nil
+			if success {
+				before, after := changedFields(before, getWebhookLogFields(webhook))
+				if webhook.Secret != oldSecret {
+					before["secret"], after["secret"] = "(hidden)", "(changed)"
+				}
+				newModLogEntry(r, currentUser, models.ModActionWebhookUpdate).OnWebhook(webhook).Change(before, after).Save()
+			}
+		}
+	}
+
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 0 {
+		page = 0
+	}
+
+	deliveries, pages, err := webhook.GetDeliveries(page)
+	if err != nil {
+		fmt.Printf("[error] Could not get webhook deliveries (%s)\n", err.Error())
+		if formError == nil {
+			formError = errors.New("Could not load the delivery log")
+		}
+	}
+
+	var prevLink, nextLink string
+	if page > 0 {
+		prevLink = fmt.Sprintf("/admin/webhooks/%d?page=%d", webhook.ID, page-1)
+	}
+	if page < pages-1 {
+		nextLink = fmt.Sprintf("/admin/webhooks/%d?page=%d", webhook.ID, page+1)
+	}
+
+	utils.RenderTemplate(w, r, "admin_webhook.html", map[string]interface{}{
+		"error":      formError,
+		"success":    success,
+		"webhook":    webhook,
+		"events":     models.WebhookEvents,
+		"deliveries": deliveries,
+		"prev_link":  prevLink,
+		"next_link":  nextLink,
+	}, nil)
+}
+
+// A webhook as recorded in the mod log. The secret is left out.
+func getWebhookLogFields(webhook *models.Webhook) map[string]interface{} {
+	return map[string]interface{}{
+		"url":    webhook.URL,
+		"events": webhook.Events,
+		"active": webhook.Active,
+	}
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Largest request body the API will read
+const apiMaxBodySize = 1 << 20
+
+// Every successful response from /api/v1 is wrapped in one of these.
+// Pagination is only present on listings.
+type apiResponse struct {
+	Data       interface{}    `json:"data"`
+	Pagination *apiPagination `json:"pagination,omitempty"`
+}
+
+// Pages are numbered from zero, the same as the ?page= parameter used by
+// the HTML pages
+type apiPagination struct {
+	Page       int  `json:"page"`
+	TotalPages int  `json:"total_pages"`
+	HasPrev    bool `json:"has_prev"`
+	HasNext    bool `json:"has_next"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiErrorResponse struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiUser struct {
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	Avatar    string     `json:"avatar"`
+	UserTitle string     `json:"user_title"`
+	CreatedOn time.Time  `json:"created_on"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	PostCount int64      `json:"post_count"`
+	Signature string     `json:"signature,omitempty"`
+}
+
+type apiBoard struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Unread      bool   `json:"unread"`
+	CanPost     bool   `json:"can_post"`
+	CanReply    bool   `json:"can_reply"`
+}
+
+type apiThread struct {
+	ID          int64     `json:"id"`
+	BoardID     int64     `json:"board_id"`
+	Title       string    `json:"title"`
+	Author      *apiUser  `json:"author"`
+	CreatedOn   time.Time `json:"created_on"`
+	LatestReply time.Time `json:"latest_reply"`
+	Sticky      bool      `json:"sticky"`
+	Locked      bool      `json:"locked"`
+	Unread      bool      `json:"unread"`
+	Pending     bool      `json:"pending,omitempty"`
+}
+
+type apiPost struct {
+	ID          int64     `json:"id"`
+	ThreadID    int64     `json:"thread_id"`
+	BoardID     int64     `json:"board_id"`
+	Title       string    `json:"title,omitempty"`
+	Author      *apiUser  `json:"author"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	CreatedOn   time.Time `json:"created_on"`
+	EditCount   int       `json:"edit_count"`
+	Pending     bool      `json:"pending,omitempty"`
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, data interface{}, pagination *apiPagination) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(apiResponse{Data: data, Pagination: pagination})
+	if err != nil {
+		fmt.Printf("[error] Could not encode API response (%s)\n", err.Error())
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(apiErrorResponse{
+		Error: apiErrorBody{Code: code, Message: message},
+	})
+}
+
+func writeAPINotFound(w http.ResponseWriter) {
+	writeAPIError(w, http.StatusNotFound, "not_found", "Not found")
+}
+
+func writeAPIMethodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+}
+
+// Catches every /api/ URL that doesn't match an endpoint, so clients get
+// JSON instead of the HTML 404 page
+func APINotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPINotFound(w)
+}
+
+func newAPIPagination(page, lastPage int) *apiPagination {
+	return &apiPagination{
+		Page:       page,
+		TotalPages: lastPage + 1,
+		HasPrev:    page > 0,
+		HasNext:    page < lastPage,
+	}
+}
+
+// Reads the ?page= parameter, writing an error if it's out of range
+func getAPIPage(w http.ResponseWriter, r *http.Request, lastPage int) (int, bool) {
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil {
+		page = 0
+	}
+
+	if page < 0 || page > lastPage {
+		writeAPIError(w, http.StatusNotFound, "page_not_found", "There is no such page")
+		return 0, false
+	}
+
+	return page, true
+}
+
+// Returns the numeric {id} from the URL
+func getAPIID(r *http.Request) int {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
+
+// Decodes a JSON request body into v, writing an error if it can't
+func readAPIBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(io.LimitReader(r.Body, apiMaxBodySize)).Decode(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Request body must be a JSON object")
+		return false
+	}
+
+	return true
+}
+
+// Returns the user making the request, writing an error and returning nil
+// if there isn't one. Requests that change something must also come from
+// someone who isn't banned, and either pass the CSRF check or carry an API
+// token that isn't read only.
+func requireAPIUser(w http.ResponseWriter, r *http.Request) *models.User {
+	user := utils.GetCurrentUser(r)
+	if user == nil {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized", "You must be logged in or send a valid API token")
+		return nil
+	}
+
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return user
+	}
+
+	// Scripts using a token have no session to forge requests against
+	token := utils.GetCurrentAPIToken(r)
+	if token == nil && !utils.CheckCSRF(r) {
+		writeAPIError(w, http.StatusForbidden, "csrf_failed", "Invalid or missing X-CSRF-Token header")
+		return nil
+	}
+
+	if token != nil && token.Scope == models.TokenScopeRead {
+		writeAPIError(w, http.StatusForbidden, "insufficient_scope", "This API token is read only")
+		return nil
+	}
+
+	if ban := getActiveBan(r, user); ban != nil {
+		message := "You are banned: " + ban.Reason
+		if !ban.IsPermanent() {
+			message += " (until " + ban.ExpiresOn.Time.Format(time.RFC3339) + ")"
+		}
+
+		writeAPIError(w, http.StatusForbidden, "banned", message)
+		return nil
+	}
+
+	return user
+}
+
+// Checks that the request's API token, if it has one, may change things on
+// the given board. Pass a nil board for changes only full access tokens
+// may make.
+func requireAPIScope(w http.ResponseWriter, r *http.Request, board *models.Board) bool {
+	token := utils.GetCurrentAPIToken(r)
+	if token != nil && !token.CanWrite(board) {
+		writeAPIError(w, http.StatusForbidden, "insufficient_scope", "This API token can't do that")
+		return false
+	}
+
+	return true
+}
+
+func newAPIUser(user *models.User, viewer *models.User) *apiUser {
+	out := &apiUser{
+		ID:        user.ID,
+		Username:  user.Username,
+		Avatar:    user.Avatar,
+		UserTitle: user.UserTitle,
+		CreatedOn: user.CreatedOn,
+		PostCount: user.GetPostCount(),
+		Signature: user.Signature.String,
+	}
+
+	if !user.HideOnline || (viewer != nil && viewer.ID == user.ID) {
+		lastSeen := user.LastSeen
+		out.LastSeen = &lastSeen
+	}
+
+	return out
+}
+
+func newAPIBoard(board *models.Board, viewer *models.User) *apiBoard {
+	return &apiBoard{
+		ID:          board.ID,
+		Title:       board.Title,
+		Description: board.Description,
+		CanPost:     models.Can(viewer, models.PermPostCreate, board),
+		CanReply:    models.Can(viewer, models.PermPostReply, board),
+	}
+}
+
+func newAPIThread(thread *models.Post, viewer *models.User) *apiThread {
+	return &apiThread{
+		ID:          thread.ID,
+		BoardID:     thread.BoardID,
+		Title:       thread.Title,
+		Author:      newAPIUser(thread.Author, viewer),
+		CreatedOn:   thread.CreatedOn,
+		LatestReply: thread.LatestReply,
+		Sticky:      thread.Sticky,
+		Locked:      thread.Locked,
+		Pending:     thread.Pending,
+	}
+}
+
+func newAPIPost(post *models.Post, viewer *models.User) *apiPost {
+	return &apiPost{
+		ID:          post.ID,
+		ThreadID:    post.GetThreadID(),
+		BoardID:     post.BoardID,
+		Title:       post.Title,
+		Author:      newAPIUser(post.Author, viewer),
+		Content:     post.Content,
+		ContentHTML: utils.RenderMarkdown(post.Content),
+		CreatedOn:   post.CreatedOn,
+		EditCount:   post.GetEditCount(),
+		Pending:     post.Pending,
+	}
+}
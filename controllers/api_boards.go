@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Returns the board with the {id} in the URL if the user can read it,
+// otherwise writes a 404
+func getAPIBoard(w http.ResponseWriter, r *http.Request, user *models.User) *models.Board {
+	board, _ := models.GetBoard(getAPIID(r))
+	if board == nil || board.IsDeleted() || !models.Can(user, models.PermBoardRead, board) {
+		writeAPINotFound(w)
+		return nil
+	}
+
+	return board
+}
+
+// GET /api/v1/boards
+func APIBoards(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	boards, err := models.GetBoardsUnread(currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get boards (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not get boards")
+		return
+	}
+
+	out := make([]*apiBoard, 0, len(boards))
+	for _, join := range boards {
+		board := newAPIBoard(join.Board, currentUser)
+		board.Unread = join.IsUnread(currentUser)
+		out = append(out, board)
+	}
+
+	writeAPIResponse(w, http.StatusOK, out, nil)
+}
+
+// GET /api/v1/boards/{id}
+func APIBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	board := getAPIBoard(w, r, currentUser)
+	if board == nil {
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, newAPIBoard(board, currentUser), nil)
+}
+
+// GET /api/v1/boards/{id}/threads lists a page of threads.
+// POST /api/v1/boards/{id}/threads starts a new one.
+func APIBoardThreads(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiListThreads(w, r)
+	case "POST":
+		apiCreateThread(w, r)
+	default:
+		writeAPIMethodNotAllowed(w, "GET, POST")
+	}
+}
+
+func apiListThreads(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	board := getAPIBoard(w, r, currentUser)
+	if board == nil {
+		return
+	}
+
+	lastPage := board.GetPagesInBoard()
+	page, ok := getAPIPage(w, r, lastPage)
+	if !ok {
+		return
+	}
+
+	threads, err := board.GetThreads(page, currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get threads (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not get threads")
+		return
+	}
+
+	out := make([]*apiThread, 0, len(threads))
+	for _, join := range threads {
+		out = append(out, &apiThread{
+			ID:          join.ID,
+			BoardID:     join.BoardID,
+			Title:       join.Title,
+			Author:      newAPIUser(join.Author, currentUser),
+			CreatedOn:   join.CreatedOn,
+			LatestReply: join.LatestReply,
+			Sticky:      join.Sticky,
+			Locked:      join.Locked,
+			Unread:      join.IsUnread(currentUser),
+		})
+	}
+
+	writeAPIResponse(w, http.StatusOK, out, newAPIPagination(page, lastPage))
+}
+
+func apiCreateThread(w http.ResponseWriter, r *http.Request) {
+	currentUser := requireAPIUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	board := getAPIBoard(w, r, currentUser)
+	if board == nil || !requireAPIScope(w, r, board) {
+		return
+	}
+
+	if !models.Can(currentUser, models.PermPostCreate, board) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "You can't start threads on this board")
+		return
+	}
+
+	var body struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if !readAPIBody(w, r, &body) {
+		return
+	}
+
+	thread := models.NewPost(currentUser, board, body.Title, body.Content)
+	if err := thread.Validate(); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid", err.Error())
+		return
+	}
+
+	if err := thread.Publish(); err != nil {
+		fmt.Printf("[error] Could not save thread (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not save thread")
+		return
+	}
+
+	queuePostWebhook(models.WebhookThreadCreate, thread, currentUser, nil)
+
+	writeAPIResponse(w, http.StatusCreated, newAPIThread(thread, currentUser), nil)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// GET /api/v1/posts/{id} returns a post, PATCH edits it and DELETE moves
+// it to the trash.
+func APIPost(w http.ResponseWriter, r *http.Request) {
+	var currentUser *models.User
+	switch r.Method {
+	case "GET":
+		currentUser = utils.GetCurrentUser(r)
+	case "PATCH", "PUT", "DELETE":
+		currentUser = requireAPIUser(w, r)
+		if currentUser == nil {
+			return
+		}
+	default:
+		writeAPIMethodNotAllowed(w, "GET, PATCH, PUT, DELETE")
+		return
+	}
+
+	post, _ := models.GetPost(getAPIID(r))
+	if post == nil || post.IsDeleted() {
+		writeAPINotFound(w)
+		return
+	}
+
+	board, _ := models.GetBoard(int(post.BoardID))
+	if board == nil || !models.Can(currentUser, models.PermBoardRead, board) || !post.IsVisibleTo(currentUser, board) {
+		writeAPINotFound(w)
+		return
+	}
+
+	if r.Method != "GET" && !requireAPIScope(w, r, board) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeAPIResponse(w, http.StatusOK, newAPIPost(post, currentUser), nil)
+	case "DELETE":
+		apiDeletePost(w, r, currentUser, board, post)
+	default:
+		apiEditPost(w, r, currentUser, board, post)
+	}
+}
+
+func apiEditPost(w http.ResponseWriter, r *http.Request, currentUser *models.User, board *models.Board, post *models.Post) {
+	if post.AuthorID != currentUser.ID && !models.Can(currentUser, models.PermPostEdit, board) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "You can't edit this post")
+		return
+	}
+
+	// Fields left out of the body keep their current value
+	body := struct {
+		Title   *string `json:"title"`
+		Content *string `json:"content"`
+		Reason  string  `json:"reason"`
+	}{}
+	if !readAPIBody(w, r, &body) {
+		return
+	}
+
+	title, content := post.Title, post.Content
+	if body.Title != nil && !post.ParentID.Valid {
+		title = *body.Title
+	}
+	if body.Content != nil {
+		content = *body.Content
+	}
+
+	if err := post.ValidateEdit(title, content); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid", err.Error())
+		return
+	}
+
+	before := map[string]string{"title": post.Title, "content": post.Content}
+	if err := post.SaveEdit(currentUser, title, content, body.Reason); err != nil {
+		fmt.Printf("[error] Could not save post (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not save post")
+		return
+	}
+
+	queuePostWebhook(models.WebhookPostEdit, post, currentUser, map[string]interface{}{
+		"reason": body.Reason,
+	})
+	publishThreadEvent(utils.ThreadEventEdit, post)
+
+	if post.AuthorID != currentUser.ID {
+		newModLogEntry(r, currentUser, models.ModActionPostEdit).OnPost(post).Change(
+			before,
+			map[string]string{"title": post.Title, "content": post.Content},
+		).WithReason(body.Reason).Save()
+	}
+
+	writeAPIResponse(w, http.StatusOK, newAPIPost(post, currentUser), nil)
+}
+
+func apiDeletePost(w http.ResponseWriter, r *http.Request, currentUser *models.User, board *models.Board, post *models.Post) {
+	if post.AuthorID != currentUser.ID && !models.Can(currentUser, models.PermPostDelete, board) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "You can't delete this post")
+		return
+	}
+
+	// The reason is optional, and so is the body
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 && !readAPIBody(w, r, &body) {
+		return
+	}
+
+	body.Reason = models.TruncateText(body.Reason, 255)
+	if err := post.SoftDelete(currentUser, body.Reason); err != nil {
+		fmt.Printf("[error] Could not delete post (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not delete post")
+		return
+	}
+
+	if post.AuthorID != currentUser.ID {
+		newModLogEntry(r, currentUser, models.ModActionPostDelete).OnPost(post).WithReason(body.Reason).Save()
+	}
+	queuePostWebhook(models.WebhookPostDelete, post, currentUser, map[string]interface{}{
+		"reason": body.Reason,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Returns the thread with the {id} in the URL along with its board, or
+// writes a 404 if it doesn't exist or the user can't read it
+func getAPIThread(w http.ResponseWriter, r *http.Request, user *models.User) (*models.Post, *models.Board) {
+	thread, _ := models.GetPost(getAPIID(r))
+	if thread == nil || thread.IsDeleted() || thread.ParentID.Valid {
+		writeAPINotFound(w)
+		return nil, nil
+	}
+
+	board, _ := models.GetBoard(int(thread.BoardID))
+	if board == nil || !models.Can(user, models.PermBoardRead, board) || !thread.IsVisibleTo(user, board) {
+		writeAPINotFound(w)
+		return nil, nil
+	}
+
+	return thread, board
+}
+
+// GET /api/v1/threads/{id}
+func APIThread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	thread, _ := getAPIThread(w, r, currentUser)
+	if thread == nil {
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, newAPIThread(thread, currentUser), nil)
+}
+
+// GET /api/v1/threads/{id}/posts lists a page of the thread, starting
+// with the OP. POST /api/v1/threads/{id}/posts replies to it.
+func APIThreadPosts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		apiListPosts(w, r)
+	case "POST":
+		apiCreateReply(w, r)
+	default:
+		writeAPIMethodNotAllowed(w, "GET, POST")
+	}
+}
+
+func apiListPosts(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	thread, _ := getAPIThread(w, r, currentUser)
+	if thread == nil {
+		return
+	}
+
+	lastPage := thread.GetPagesInThread()
+	page, ok := getAPIPage(w, r, lastPage)
+	if !ok {
+		return
+	}
+
+	err, op, posts := models.GetThread(int(thread.ID), page)
+	if err != nil {
+		writeAPINotFound(w)
+		return
+	}
+
+	// The OP is only shown on the first page, as it is on the site
+	if page == 0 {
+		posts = append([]*models.Post{op}, posts...)
+	}
+
+	out := make([]*apiPost, 0, len(posts))
+	for _, post := range posts {
+		out = append(out, newAPIPost(post, currentUser))
+	}
+
+	// Mark the thread as read
+	if currentUser != nil {
+		models.AddView(currentUser, op)
+	}
+
+	writeAPIResponse(w, http.StatusOK, out, newAPIPagination(page, lastPage))
+}
+
+func apiCreateReply(w http.ResponseWriter, r *http.Request) {
+	currentUser := requireAPIUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	thread, board := getAPIThread(w, r, currentUser)
+	if thread == nil || !requireAPIScope(w, r, board) {
+		return
+	}
+
+	if !models.Can(currentUser, models.PermPostReply, board) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "You can't reply on this board")
+		return
+	}
+
+	// Nobody replies to a thread until it's been approved
+	if thread.Pending {
+		writeAPIError(w, http.StatusForbidden, "pending", "This thread is waiting for approval")
+		return
+	}
+
+	if thread.Locked && !models.Can(currentUser, models.PermThreadLock, board) {
+		writeAPIError(w, http.StatusForbidden, "locked", "This thread is locked")
+		return
+	}
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if !readAPIBody(w, r, &body) {
+		return
+	}
+
+	post := models.NewPost(currentUser, board, "", body.Content)
+	post.ParentID = sql.NullInt64{Int64: thread.ID, Valid: true}
+
+	if err := post.Validate(); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid", err.Error())
+		return
+	}
+
+	if err := post.Publish(); err != nil {
+		fmt.Printf("[error] Could not save reply (%s)\n", err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not save reply")
+		return
+	}
+
+	queuePostWebhook(models.WebhookPostReply, post, currentUser, nil)
+	publishThreadEvent(utils.ThreadEventReply, post)
+
+	writeAPIResponse(w, http.StatusCreated, newAPIPost(post, currentUser), nil)
+}
+
+// POST /api/v1/threads/{id}/{action}, where the action is one of stick,
+// unstick, lock, unlock or move. Moves take the target board_id in the
+// body. Every action accepts an optional reason for the mod log.
+func APIThreadModerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeAPIMethodNotAllowed(w, "POST")
+		return
+	}
+
+	currentUser := requireAPIUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	thread, board := getAPIThread(w, r, currentUser)
+	if thread == nil || !requireAPIScope(w, r, nil) {
+		return
+	}
+
+	var body struct {
+		BoardID int64  `json:"board_id"`
+		Reason  string `json:"reason"`
+	}
+	if r.ContentLength != 0 && !readAPIBody(w, r, &body) {
+		return
+	}
+
+	var err error
+	var logAction, event string
+	var before, after map[string]int64
+	extra := map[string]interface{}{"reason": body.Reason}
+
+	switch action := mux.Vars(r)["action"]; action {
+	case "stick", "unstick":
+		if !models.Can(currentUser, models.PermThreadStick, board) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "You can't stick threads on this board")
+			return
+		}
+
+		err = thread.SetSticky(action == "stick")
+		logAction = models.ModActionThreadStick
+		if action == "unstick" {
+			logAction = models.ModActionThreadUnstick
+		}
+	case "lock", "unlock":
+		if !models.Can(currentUser, models.PermThreadLock, board) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "You can't lock threads on this board")
+			return
+		}
+
+		err = thread.SetLocked(action == "lock")
+		logAction, event = models.ModActionThreadLock, models.WebhookThreadLock
+		if action == "unlock" {
+			logAction, event = models.ModActionThreadUnlock, models.WebhookThreadUnlock
+		}
+	case "move":
+		if !models.Can(currentUser, models.PermThreadMove, board) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "You can't move threads on this board")
+			return
+		}
+
+		target, _ := models.GetBoard(int(body.BoardID))
+		if target == nil || target.IsDeleted() || !models.Can(currentUser, models.PermThreadMove, target) {
+			writeAPIError(w, http.StatusUnprocessableEntity, "invalid", "You can't move threads to that board")
+			return
+		}
+
+		err = thread.MoveTo(target)
+		logAction, event = models.ModActionThreadMove, models.WebhookThreadMove
+		before = map[string]int64{"board_id": board.ID}
+		after = map[string]int64{"board_id": target.ID}
+		extra["from_board"] = newWebhookBoard(board)
+	default:
+		writeAPINotFound(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("[error] Could not moderate thread %d (%s)\n", thread.ID, err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "internal", "Could not update thread")
+		return
+	}
+
+	entry := newModLogEntry(r, currentUser, logAction).OnPost(thread)
+	if before != nil {
+		entry.Change(before, after)
+	}
+	entry.WithReason(body.Reason).Save()
+
+	// Sticking threads isn't something webhooks are told about
+	if event != "" {
+		queuePostWebhook(event, thread, currentUser, extra)
+	}
+	writeAPIResponse(w, http.StatusOK, newAPIThread(thread, currentUser), nil)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// GET /api/v1/me returns the user the request is authenticated as
+func APIMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	currentUser := requireAPIUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, newAPIUser(currentUser, currentUser), nil)
+}
+
+// GET /api/v1/users/{id}
+func APIUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	user, _ := models.GetUser(getAPIID(r))
+	if user == nil {
+		writeAPINotFound(w)
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, newAPIUser(user, utils.GetCurrentUser(r)), nil)
+}
+
+// GET /api/v1/users/{id}/posts lists the user's posts, newest first,
+// leaving out boards the visitor can't read
+func APIUserPosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIMethodNotAllowed(w, "GET")
+		return
+	}
+
+	user, _ := models.GetUser(getAPIID(r))
+	if user == nil {
+		writeAPINotFound(w)
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	lastPage := user.GetPagesOfPosts(currentUser)
+	page, ok := getAPIPage(w, r, lastPage)
+	if !ok {
+		return
+	}
+
+	posts := user.GetPosts(page, currentUser)
+	out := make([]*apiPost, 0, len(posts))
+	for _, post := range posts {
+		out = append(out, newAPIPost(post, currentUser))
+	}
+
+	writeAPIResponse(w, http.StatusOK, out, newAPIPagination(page, lastPage))
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Approves or rejects a post in the queue, returning a note on what was
+// done
+func handlePendingPost(r *http.Request, currentUser *models.User, post *models.Post) (string, error) {
+	switch r.FormValue("action") {
+	case "approve":
+		if err := post.Approve(); err != nil {
+			return "", err
+		}
+
+		newModLogEntry(r, currentUser, models.ModActionPostApprove).OnPost(post).Save()
+
+		// Approving a post says it isn't spam
+		if err := models.MarkSpam(post, currentUser, false); err != nil {
+			fmt.Printf("[error] Could not teach the spam filter (%s)\n", err.Error())
+		}
+
+		author, _ := models.GetUser(int(post.AuthorID))
+		if post.ParentID.Valid {
+			queuePostWebhook(models.WebhookPostReply, post, author, nil)
+			publishThreadEvent(utils.ThreadEventReply, post)
+		} else {
+			queuePostWebhook(models.WebhookThreadCreate, post, author, nil)
+		}
+
+		return "Post approved", nil
+
+	case "reject":
+		reason := models.TruncateText(r.FormValue("reason"), 255)
+		if err := post.Reject(currentUser, reason); err != nil {
+			return "", err
+		}
+
+		newModLogEntry(r, currentUser, models.ModActionPostReject).OnPost(post).WithReason(reason).Save()
+		return "Post rejected", nil
+
+	case "spam":
+		if err := markPostAsSpam(r, currentUser, post); err != nil {
+			return "", err
+		}
+
+		return "Post deleted as spam", nil
+	}
+
+	return "", errors.New("Unknown action")
+}
+
+// The moderator queue of posts waiting for approval
+func PendingPosts(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil || !currentUser.CanApprovePosts() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	var notice string
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		postID, _ := strconv.Atoi(r.FormValue("post_id"))
+		post, _ := models.GetPost(postID)
+		if post == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		board, _ := models.GetBoard(int(post.BoardID))
+		if board == nil || !models.Can(currentUser, models.PermPostApprove, board) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !post.Pending || post.IsDeleted() {
+			formError = errors.New("Someone else has already handled that post")
+		} else {
+			notice, formError = handlePendingPost(r, currentUser, post)
+		}
+	}
+
+	posts, err := models.GetPendingPosts(currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get pending posts (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "pending.html", map[string]interface{}{
+		"posts":  posts,
+		"error":  formError,
+		"notice": notice,
+	}, nil)
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Returns the ban covering either the user's account or the address the
+// request came from, or nil if neither is banned
+func getActiveBan(r *http.Request, user *models.User) *models.Ban {
+	if ban := models.GetActiveUserBan(user); ban != nil {
+		return ban
+	}
+
+	if models.IsExemptFromIPBans(user) {
+		return nil
+	}
+
+	return models.GetActiveIPBan(utils.GetRemoteIP(r))
+}
+
+// Explains to a banned user why they can't do what they just tried to
+func renderBanned(w http.ResponseWriter, r *http.Request, ban *models.Ban) {
+	w.WriteHeader(http.StatusForbidden)
+	utils.RenderTemplate(w, r, "banned.html", map[string]interface{}{
+		"ban": ban,
+	}, nil)
+}
+
+// Reads the ban length picked in a ban form. Zero means permanent.
+func getBanDuration(r *http.Request) time.Duration {
+	days, _ := strconv.Atoi(r.FormValue("duration"))
+	if days < 0 {
+		days = 0
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Bans the user, along with an IP range if one is given, and signs them
+// out everywhere. The ban form and the reports queue both ban through
+// here so that the same checks apply to each.
+func banUser(r *http.Request, currentUser *models.User, user *models.User, ipRange, reason string) (*models.Ban, error) {
+	if err := models.CheckCanBan(currentUser, user); err != nil {
+		return nil, err
+	}
+
+	if ipRange != "" {
+		if err := models.CheckCanBanIPRange(currentUser, ipRange, utils.GetRemoteIP(r)); err != nil {
+			return nil, err
+		}
+	}
+
+	ban := models.NewBan(user, ipRange, currentUser, reason, getBanDuration(r))
+	if err := ban.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := models.GetDbSession().Insert(ban); err != nil {
+		fmt.Printf("[error] Could not save ban (%s)\n", err.Error())
+		return nil, errors.New("Could not save the ban")
+	}
+
+	// Log them out everywhere so the ban takes effect straight away
+	if err := user.RevokeOtherSessions(0); err != nil {
+		fmt.Printf("[error] Could not sign out banned user (%s)\n", err.Error())
+	}
+
+	newModLogEntry(r, currentUser, models.ModActionUserBan).OnBan(ban).Change(nil, getBanLogFields(ban)).WithReason(ban.Reason).Save()
+	return ban, nil
+}
+
+func UserBan(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermUserBan, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	user, err := models.GetUser(userID)
+	if err != nil || user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError string
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		var ipRange string
+		if raw := r.FormValue("ip_range"); raw != "" {
+			ipRange, err = models.NormalizeIPRange(raw)
+			if err != nil {
+				formError = err.Error()
+			}
+		}
+
+		if formError == "" {
+			if _, err := banUser(r, currentUser, user, ipRange, r.FormValue("reason")); err != nil {
+				formError = err.Error()
+			} else {
+				http.Redirect(w, r, fmt.Sprintf("/user/%d", user.ID), http.StatusFound)
+				return
+			}
+		}
+	}
+
+	sessions, _ := user.GetSessions()
+	activeBan := models.GetActiveUserBan(user)
+
+	utils.RenderTemplate(w, r, "user_ban.html", map[string]interface{}{
+		"user":      user,
+		"sessions":  sessions,
+		"activeBan": activeBan,
+		"error":     formError,
+	}, nil)
+}
+
+// Lists the bans in effect, adds bans on IP ranges and lifts bans early
+func AdminBans(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermUserBan, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError string
+	success := false
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if id := r.FormValue("lift"); id != "" {
+			banID, _ := strconv.Atoi(id)
+			ban, _ := models.GetBan(banID)
+			if ban == nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			if err := ban.CheckCanLift(currentUser); err != nil {
+				formError = err.Error()
+			} else if err := ban.Lift(currentUser); err != nil {
+				fmt.Printf("[error] Could not lift ban (%s)\n", err.Error())
+				formError = "Could not lift the ban"
+			} else {
+				newModLogEntry(r, currentUser, models.ModActionBanLift).OnBan(ban).WithReason(r.FormValue("reason")).Save()
+				if next := r.FormValue("next"); next == "user" && ban.UserID.Valid {
+					http.Redirect(w, r, fmt.Sprintf("/admin/users/%d", ban.UserID.Int64), http.StatusFound)
+					return
+				}
+
+				success = true
+			}
+		} else {
+			ipRange, err := models.NormalizeIPRange(r.FormValue("ip_range"))
+			if err == nil {
+				err = models.CheckCanBanIPRange(currentUser, ipRange, utils.GetRemoteIP(r))
+			}
+
+			if err != nil {
+				formError = err.Error()
+			} else {
+				ban := models.NewBan(nil, ipRange, currentUser, r.FormValue("reason"), getBanDuration(r))
+				if err = ban.Validate(); err != nil {
+					formError = err.Error()
+				} else if err = models.GetDbSession().Insert(ban); err != nil {
+					fmt.Printf("[error] Could not save ban (%s)\n", err.Error())
+					formError = "Could not save the ban"
+				} else {
+					newModLogEntry(r, currentUser, models.ModActionIPBan).OnBan(ban).Change(nil, getBanLogFields(ban)).WithReason(ban.Reason).Save()
+					success = true
+				}
+			}
+		}
+	}
+
+	bans, _ := models.GetActiveBans()
+
+	utils.RenderTemplate(w, r, "admin_bans.html", map[string]interface{}{
+		"bans":    bans,
+		"error":   formError,
+		"success": success,
+	}, nil)
+}
+
+// What the mod log records about a new ban
+func getBanLogFields(ban *models.Ban) map[string]interface{} {
+	fields := map[string]interface{}{"permanent": ban.IsPermanent()}
+	if ban.IPRange.Valid {
+		fields["ip_range"] = ban.IPRange.String
+	}
+	if !ban.IsPermanent() {
+		fields["expires_on"] = ban.ExpiresOn.Time
+	}
+
+	return fields
+}
@@ -34,25 +34,32 @@ func Board(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
 	threads, err := board.GetThreads(page_id, currentUser)
 	if err != nil {
 		fmt.Printf("[error] Could not get posts (%s)\n", err.Error())
 	}
 
 	num_pages := board.GetPagesInBoard()
+	moderators, _ := board.GetModerators()
 
 	utils.RenderTemplate(w, r, "board.html", map[string]interface{}{
-		"board":     board,
-		"threads":   threads,
-		"page_id":   page_id,
-		"prev_page": (page_id != 0),
-		"next_page": (page_id < num_pages),
+		"board":      board,
+		"moderators": moderators,
+		"threads":    threads,
+		"page_id":    page_id,
+		"prev_page":  (page_id != 0),
+		"next_page":  (page_id < num_pages),
+		"feed_path":  fmt.Sprintf("/board/%d/feed", board.ID),
+		"feed_title": board.Title,
+		"watching":   currentUser != nil && currentUser.IsWatchingBoard(board),
 	}, map[string]interface{}{
 		"IsUnread": func(join *models.JoinThreadView) bool {
-			if currentUser != nil && !currentUser.LastUnreadAll.Time.Before(join.LatestReply) {
-				return false
-			}
-			return !join.ViewedOn.Valid || join.ViewedOn.Time.Before(join.LatestReply)
+			return join.IsUnread(currentUser)
 		},
 	})
 }
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Bookmarks a post, changes the note on an existing bookmark or removes
+// it. GET requests show the form.
+func ActionBookmark(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetCurrentUser(r)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	postID, _ := strconv.Atoi(r.FormValue("post_id"))
+	post, err := models.GetPost(postID)
+	if post == nil || err != nil || post.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, _ := models.GetBoard(int(post.BoardID))
+	if board == nil || !models.Can(user, models.PermBoardRead, board) || !post.IsVisibleTo(user, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	bookmark := user.GetBookmark(post)
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if r.FormValue("remove") != "" {
+			if bookmark != nil {
+				formError = bookmark.Delete()
+			}
+		} else if bookmark == nil {
+			bookmark = models.NewBookmark(user, post, r.FormValue("note"))
+			formError = bookmark.Validate()
+			if formError == nil {
+				formError = models.GetDbSession().Insert(bookmark)
+			}
+		} else {
+			edited := models.NewBookmark(user, post, r.FormValue("note"))
+			formError = edited.Validate()
+			if formError == nil {
+				bookmark.Note = edited.Note
+				_, formError = models.GetDbSession().Update(bookmark)
+			}
+		}
+
+		if formError == nil {
+			redirect := post.GetLink()
+			if r.FormValue("from") == "bookmarks" {
+				redirect = "/bookmarks"
+			}
+
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+	}
+
+	utils.RenderTemplate(w, r, "action_bookmark.html", map[string]interface{}{
+		"error":    formError,
+		"board":    board,
+		"post":     post,
+		"bookmark": bookmark,
+	}, nil)
+}
+
+// Lists the current user's bookmarks, newest first
+func Bookmarks(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	bookmarks, err := currentUser.GetBookmarks()
+	if err != nil {
+		fmt.Printf("[error] Could not get bookmarks (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "bookmarks.html", map[string]interface{}{
+		"bookmarks": bookmarks,
+	}, nil)
+}
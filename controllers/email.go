@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Follows the link from a verification email
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := models.VerifyEmail(mux.Vars(r)["token"])
+	if err != nil || user == nil {
+		utils.RenderTemplate(w, r, "email_verified.html", map[string]interface{}{
+			"error": "This verification link is invalid or has already been used.",
+		}, nil)
+		return
+	}
+
+	utils.RenderTemplate(w, r, "email_verified.html", map[string]interface{}{
+		"user": user,
+	}, nil)
+}
+
+// Turns off some or all emails for the user the token belongs to. GET
+// requests ask for confirmation, so link scanners can't unsubscribe people.
+// POSTs come from that form or straight from mail clients (RFC 8058), so
+// the token stands in for the usual session and CSRF checks.
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	user, _ := models.GetUserByUnsubscribeToken(mux.Vars(r)["token"])
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	list := r.FormValue("list")
+	if list != utils.UnsubscribeNotifications && list != utils.UnsubscribeDigest {
+		list = utils.UnsubscribeAll
+	}
+
+	done := false
+	if r.Method == "POST" {
+		if list == utils.UnsubscribeNotifications || list == utils.UnsubscribeAll {
+			user.EmailNotifications = false
+		}
+		if list == utils.UnsubscribeDigest || list == utils.UnsubscribeAll {
+			user.EmailDigest = models.DigestOff
+		}
+
+		if _, err := models.GetDbSession().Update(user); err != nil {
+			fmt.Printf("[error] Could not unsubscribe user (%s)\n", err.Error())
+			http.Error(w, "Could not unsubscribe", http.StatusInternalServerError)
+			return
+		}
+
+		done = true
+	}
+
+	utils.RenderTemplate(w, r, "unsubscribe.html", map[string]interface{}{
+		"user": user,
+		"list": list,
+		"done": done,
+	}, nil)
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/config"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// How many entries the site-wide feed holds
+const siteFeedLength = 30
+
+func newFeedEntry(post *models.Post, title string) *utils.FeedEntry {
+	updated := post.CreatedOn
+	if post.LastEdit.After(updated) {
+		updated = post.LastEdit
+	}
+
+	return &utils.FeedEntry{
+		Title:     title,
+		Link:      utils.AbsoluteURL(post.GetLink()),
+		Author:    post.Author.Username,
+		AuthorURL: utils.AbsoluteURL(fmt.Sprintf("/user/%d", post.AuthorID)),
+		Content:   utils.RenderMarkdown(post.Content),
+		Published: post.CreatedOn,
+		Updated:   updated,
+	}
+}
+
+// Sets the feed's updated time to that of its newest entry
+func setFeedUpdated(feed *utils.Feed) {
+	for _, entry := range feed.Entries {
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+	}
+
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now()
+	}
+}
+
+// Recent threads and replies across every board the visitor can read
+func SiteFeed(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	siteName, _ := config.Config.GetString("gobb", "site_name")
+
+	posts, err := models.GetRecentPosts(currentUser, siteFeedLength)
+	if err != nil {
+		fmt.Printf("[error] Could not get recent posts (%s)\n", err.Error())
+	}
+
+	feed := &utils.Feed{
+		Title:       siteName,
+		Description: "Recent posts on " + siteName,
+		Link:        utils.AbsoluteURL("/"),
+		SelfLink:    utils.AbsoluteURL("/feed"),
+	}
+
+	// Replies are titled after their thread, which is usually shared by
+	// several of them
+	titles := map[int64]string{}
+	for _, post := range posts {
+		threadID := post.GetThreadID()
+		if _, ok := titles[threadID]; !ok {
+			titles[threadID] = post.Title
+			if post.ParentID.Valid {
+				if op, _ := models.GetPost(int(threadID)); op != nil {
+					titles[threadID] = op.Title
+				}
+			}
+		}
+
+		title := titles[threadID]
+		if post.ParentID.Valid {
+			title = "Re: " + title
+		}
+
+		feed.Entries = append(feed.Entries, newFeedEntry(post, title))
+	}
+
+	setFeedUpdated(feed)
+	utils.RenderFeed(w, feed, mux.Vars(r)["format"])
+}
+
+// The threads in a board, most recently active first
+func BoardFeed(w http.ResponseWriter, r *http.Request) {
+	boardID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	board, err := models.GetBoard(boardID)
+	if err != nil || board == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	threads, err := board.GetThreads(0, currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get threads (%s)\n", err.Error())
+	}
+
+	// Stickies are listed first on the board, but a feed is in date order
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].LatestReply.After(threads[j].LatestReply)
+	})
+
+	siteName, _ := config.Config.GetString("gobb", "site_name")
+	feed := &utils.Feed{
+		Title:       board.Title + " - " + siteName,
+		Description: board.Description,
+		Link:        utils.AbsoluteURL(fmt.Sprintf("/board/%d", board.ID)),
+		SelfLink:    utils.AbsoluteURL(fmt.Sprintf("/board/%d/feed", board.ID)),
+	}
+
+	for _, join := range threads {
+		op, _ := models.GetPost(int(join.ID))
+		if op == nil {
+			continue
+		}
+
+		entry := newFeedEntry(op, op.Title)
+		entry.Link = utils.AbsoluteURL(fmt.Sprintf("/board/%d/%d", op.BoardID, op.ID))
+		entry.Updated = join.LatestReply
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	setFeedUpdated(feed)
+	utils.RenderFeed(w, feed, mux.Vars(r)["format"])
+}
+
+// The latest replies in a thread, newest first
+func ThreadFeed(w http.ResponseWriter, r *http.Request) {
+	boardID, _ := strconv.Atoi(mux.Vars(r)["board_id"])
+	board, _ := models.GetBoard(boardID)
+
+	postID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	op, _ := models.GetPost(postID)
+	if board == nil || op == nil || op.IsDeleted() || op.Pending || op.ParentID.Valid || op.BoardID != board.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	lastPage := op.GetPagesInThread()
+	err, op, posts := models.GetThread(postID, lastPage)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Short last pages are topped up with the one before
+	firstPage := lastPage
+	if len(posts) < siteFeedLength/2 && lastPage > 0 {
+		firstPage--
+		_, _, previous := models.GetThread(postID, firstPage)
+		posts = append(previous, posts...)
+	}
+
+	if firstPage == 0 {
+		posts = append([]*models.Post{op}, posts...)
+	}
+
+	siteName, _ := config.Config.GetString("gobb", "site_name")
+	feed := &utils.Feed{
+		Title:       op.Title + " - " + siteName,
+		Description: "Replies to " + op.Title + " in " + board.Title,
+		Link:        utils.AbsoluteURL(fmt.Sprintf("/board/%d/%d", board.ID, op.ID)),
+		SelfLink:    utils.AbsoluteURL(fmt.Sprintf("/board/%d/%d/feed", board.ID, op.ID)),
+	}
+
+	for i := len(posts) - 1; i >= 0; i-- {
+		title := "Re: " + op.Title
+		if posts[i].ID == op.ID {
+			title = op.Title
+		}
+
+		feed.Entries = append(feed.Entries, newFeedEntry(posts[i], title))
+	}
+
+	setFeedUpdated(feed)
+	utils.RenderFeed(w, feed, mux.Vars(r)["format"])
+}
@@ -18,7 +18,7 @@ func Index(w http.ResponseWriter, request *http.Request) {
 
 	user_count, _ := models.GetUserCount()
 	latest_user, _ := models.GetLatestUser()
-	total_posts, _ := models.GetPostCount()
+	total_posts, _ := models.GetPostCount(currentUser)
 
 	utils.RenderTemplate(w, request, "index.html", map[string]interface{}{
 		"boards":       boards,
@@ -28,13 +28,7 @@ func Index(w http.ResponseWriter, request *http.Request) {
 		"total_posts":  total_posts,
 	}, map[string]interface{}{
 		"IsUnread": func(join *models.JoinBoardView) bool {
-			latest_post := join.Board.GetLatestPost()
-
-			if currentUser != nil && !currentUser.LastUnreadAll.Time.Before(latest_post.Op.LatestReply) {
-				return false
-			}
-
-			return !join.ViewedOn.Valid || join.ViewedOn.Time.Before(latest_post.Op.LatestReply)
+			return join.IsUnread(currentUser)
 		},
 	})
 }
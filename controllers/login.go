@@ -19,7 +19,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		password := r.FormValue("password")
 
 		var error string
-		_, err := models.AuthenticateUser(username, password)
+		user, err := models.AuthenticateUser(username, password)
 		if err != nil {
 			error = "Invalid username or password"
 		}
@@ -31,11 +31,12 @@ func Login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		session, _ := utils.GetCookieStore(r).Get(r, "sirsid")
-		session.Values["username"] = username
-		session.Values["password"] = password
+		if ban := getActiveBan(r, user); ban != nil {
+			renderBanned(w, r, ban)
+			return
+		}
 
-		err = session.Save(r, w)
+		err = utils.StartSession(w, r, user)
 		if err != nil {
 			fmt.Printf("[error] Could not save session (%s)\n", err.Error())
 		}
@@ -7,13 +7,18 @@ import (
 )
 
 func Logout(w http.ResponseWriter, r *http.Request) {
-	session, _ := utils.GetCookieStore(r).Get(r, "sirsid")
-	delete(session.Values, "username")
-	delete(session.Values, "password")
+	if utils.GetCurrentUser(r) == nil {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
 
-	err := session.Save(r, w)
+	err := utils.EndSession(w, r)
 	if err != nil {
-		fmt.Printf("[error] Could not save session (%s)\n", err.Error)
+		fmt.Printf("[error] Could not save session (%s)\n", err.Error())
 	}
 
 	http.Redirect(w, r, "/", http.StatusFound)
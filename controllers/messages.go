@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Shows a page of the user's inbox or outbox
+func renderMailbox(w http.ResponseWriter, r *http.Request, currentUser *models.User, sent bool) {
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 0 {
+		page = 0
+	}
+
+	path := "/messages"
+	getConversations := currentUser.GetInbox
+	if sent {
+		path = "/messages/sent"
+		getConversations = currentUser.GetOutbox
+	}
+
+	conversations, pages, err := getConversations(page)
+	if err != nil {
+		fmt.Printf("[error] Could not get conversations (%s)\n", err.Error())
+	}
+
+	var prevLink, nextLink string
+	if page > 0 {
+		prevLink = fmt.Sprintf("%s?page=%d", path, page-1)
+	}
+	if page < pages-1 {
+		nextLink = fmt.Sprintf("%s?page=%d", path, page+1)
+	}
+
+	utils.RenderTemplate(w, r, "messages.html", map[string]interface{}{
+		"conversations": conversations,
+		"sent":          sent,
+		"prev_link":     prevLink,
+		"next_link":     nextLink,
+	}, nil)
+}
+
+func Messages(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderMailbox(w, r, currentUser, false)
+}
+
+func SentMessages(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderMailbox(w, r, currentUser, true)
+}
+
+// Starts a new conversation. The recipients can be filled in from the
+// query string, which is how the link on profiles works.
+func NewConversation(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if ban := getActiveBan(r, currentUser); ban != nil {
+		renderBanned(w, r, ban)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		recipients, err := models.GetMessageRecipients(currentUser, r.FormValue("to"))
+		formError = err
+
+		var conversation *models.Conversation
+		if formError == nil {
+			conversation, formError = models.NewConversation(currentUser, recipients, r.FormValue("subject"), r.FormValue("content"))
+		}
+
+		if formError == nil {
+			http.Redirect(w, r, fmt.Sprintf("/messages/%d", conversation.ID), http.StatusFound)
+			return
+		}
+	}
+
+	utils.RenderTemplate(w, r, "new_conversation.html", map[string]interface{}{
+		"error":   formError,
+		"to":      r.FormValue("to"),
+		"subject": r.FormValue("subject"),
+		"content": r.FormValue("content"),
+	}, nil)
+}
+
+// Shows a conversation to one of its participants and takes their replies
+func Conversation(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	conversation, _ := models.GetConversation(id)
+	if conversation == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	participant := conversation.GetParticipant(currentUser)
+	if participant == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if ban := getActiveBan(r, currentUser); ban != nil {
+			renderBanned(w, r, ban)
+			return
+		}
+
+		message, err := conversation.Reply(currentUser, r.FormValue("content"))
+		formError = err
+		if formError == nil {
+			http.Redirect(w, r, fmt.Sprintf("/messages/%d#message_%d", conversation.ID, message.ID), http.StatusFound)
+			return
+		}
+	}
+
+	messages, err := conversation.GetMessages(currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get messages (%s)\n", err.Error())
+	}
+
+	// Read receipts are worked out before this visit counts as reading it,
+	// so the user can still see what's new to them
+	lastRead := participant.LastRead
+	if err := participant.MarkRead(); err != nil {
+		fmt.Printf("[error] Could not mark conversation read (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "conversation.html", map[string]interface{}{
+		"conversation": conversation,
+		"messages":     messages,
+		"participants": conversation.GetParticipants(),
+		"last_read":    lastRead,
+		"error":        formError,
+		"content":      r.FormValue("content"),
+	}, nil)
+}
+
+func ActionLeaveConversation(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
+	id, _ := strconv.Atoi(r.FormValue("conversation_id"))
+	conversation, _ := models.GetConversation(id)
+	if conversation == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	participant := conversation.GetParticipant(currentUser)
+	if participant == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := participant.Leave(); err != nil {
+		fmt.Printf("[error] Could not leave conversation (%s)\n", err.Error())
+	}
+
+	http.Redirect(w, r, "/messages", http.StatusFound)
+}
+
+// Blocks or unblocks a user. Blocking is done from a conversation and
+// unblocking from the list of blocked users.
+func ActionBlockUser(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.FormValue("user_id"))
+	user, _ := models.GetUser(userID)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	if r.FormValue("unblock") != "" {
+		err = currentUser.Unblock(user)
+	} else {
+		err = currentUser.Block(user)
+	}
+	if err != nil {
+		fmt.Printf("[error] Could not update block (%s)\n", err.Error())
+	}
+
+	if r.FormValue("from") == "blocked" {
+		http.Redirect(w, r, "/messages/blocked", http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, "/messages", http.StatusFound)
+}
+
+func BlockedUsers(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	users, err := currentUser.GetBlockedUsers()
+	if err != nil {
+		fmt.Printf("[error] Could not get blocked users (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "blocked_users.html", map[string]interface{}{
+		"users": users,
+	}, nil)
+}
+
+// Lets a participant report a message someone else sent them
+func ActionReportMessage(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	messageID, _ := strconv.Atoi(r.FormValue("message_id"))
+	message, _ := models.GetMessage(messageID)
+	if message == nil || message.IsRemoved() || message.AuthorID == currentUser.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	conversation := message.GetConversation()
+	if conversation == nil || conversation.GetParticipant(currentUser) == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		formError = models.ReportMessage(currentUser, message, r.FormValue("reason"))
+		if formError == nil {
+			http.Redirect(w, r, fmt.Sprintf("/messages/%d#message_%d", conversation.ID, message.ID), http.StatusFound)
+			return
+		}
+	}
+
+	utils.RenderTemplate(w, r, "report_message.html", map[string]interface{}{
+		"message":      message,
+		"conversation": conversation,
+		"error":        formError,
+	}, nil)
+}
+
+// Lists reported messages for moderators. Only the reported message is
+// shown, never the conversation around it.
+func AdminMessageReports(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermMessageModerate, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		reportID, _ := strconv.Atoi(r.FormValue("report_id"))
+		report, _ := models.GetMessageReport(reportID)
+		var message *models.Message
+		if report != nil {
+			message = report.GetMessage()
+		}
+
+		if message == nil {
+			formError = errors.New("That report no longer exists")
+		} else if r.FormValue("remove") != "" {
+			formError = message.Remove(currentUser)
+			if formError == nil {
+				formError = message.ResolveReports(currentUser, models.MessageReportRemoved)
+			}
+			if formError == nil {
+				newModLogEntry(r, currentUser, models.ModActionMessageRemove).OnMessage(message).WithReason(report.Reason).Save()
+			}
+		} else {
+			formError = message.ResolveReports(currentUser, models.MessageReportDismissed)
+			if formError == nil {
+				newModLogEntry(r, currentUser, models.ModActionMessageDismiss).OnMessage(message).WithReason(report.Reason).Save()
+			}
+		}
+
+		if formError == nil {
+			http.Redirect(w, r, "/admin/message_reports", http.StatusFound)
+			return
+		}
+	}
+
+	reports, err := models.GetOpenMessageReports()
+	if err != nil {
+		fmt.Printf("[error] Could not get message reports (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "admin_message_reports.html", map[string]interface{}{
+		"reports": reports,
+		"error":   formError,
+	}, nil)
+}
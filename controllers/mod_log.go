@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Starts a mod log entry for an action taken in this request
+func newModLogEntry(r *http.Request, actor *models.User, action string) *models.ModLogEntry {
+	return models.NewModLogEntry(actor, action, utils.GetRemoteIP(r))
+}
+
+// Drops the values which are the same before and after, so the log only
+// shows what actually changed
+func changedFields(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	oldValues := map[string]interface{}{}
+	newValues := map[string]interface{}{}
+	for key, value := range after {
+		if before[key] != value {
+			oldValues[key] = before[key]
+			newValues[key] = value
+		}
+	}
+
+	return oldValues, newValues
+}
+
+func AdminModLog(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermModLogView, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError string
+	filter := &models.ModLogFilter{}
+
+	if name := r.FormValue("moderator"); name != "" {
+		if user, _ := models.GetUserByUsername(name); user != nil {
+			filter.ActorID = user.ID
+		} else {
+			formError = "There's no user called " + name
+		}
+	}
+
+	if name := r.FormValue("user"); name != "" {
+		if user, _ := models.GetUserByUsername(name); user != nil {
+			filter.TargetUserID = user.ID
+		} else {
+			formError = "There's no user called " + name
+		}
+	}
+
+	boardID, _ := strconv.Atoi(r.FormValue("board"))
+	filter.BoardID = int64(boardID)
+
+	if from := r.FormValue("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			formError = "Dates should look like 2006-01-02"
+		}
+		filter.From = t
+	}
+
+	// The "to" date is inclusive
+	if to := r.FormValue("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			formError = "Dates should look like 2006-01-02"
+		} else {
+			filter.To = t.AddDate(0, 0, 1)
+		}
+	}
+
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 0 {
+		page = 0
+	}
+
+	var entries []*models.ModLogEntry
+	numPages := 0
+	if formError == "" {
+		entries, _ = models.GetModLog(filter, page)
+		numPages = models.GetModLogPages(filter)
+	}
+
+	boards, _ := models.GetBoards()
+
+	// Keeps the filters when paging
+	pageLink := func(page int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		return "/admin/modlog?" + query.Encode()
+	}
+
+	utils.RenderTemplate(w, r, "admin_mod_log.html", map[string]interface{}{
+		"entries":   entries,
+		"boards":    boards,
+		"error":     formError,
+		"moderator": r.FormValue("moderator"),
+		"user_name": r.FormValue("user"),
+		"board_id":  int64(boardID),
+		"from":      r.FormValue("from"),
+		"to":        r.FormValue("to"),
+		"prev_page": page > 0,
+		"next_page": page < numPages-1,
+		"prev_link": pageLink(page - 1),
+		"next_link": pageLink(page + 1),
+	}, nil)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Lists the current user's notifications, newest first
+func Notifications(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if err := currentUser.MarkAllNotificationsRead(); err != nil {
+			fmt.Printf("[error] Could not mark notifications read (%s)\n", err.Error())
+		}
+
+		http.Redirect(w, r, "/notifications", http.StatusFound)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 0 {
+		page = 0
+	}
+
+	notifications, pages, err := currentUser.GetNotifications(page)
+	if err != nil {
+		fmt.Printf("[error] Could not get notifications (%s)\n", err.Error())
+	}
+
+	var prevLink, nextLink string
+	if page > 0 {
+		prevLink = fmt.Sprintf("/notifications?page=%d", page-1)
+	}
+	if page < pages-1 {
+		nextLink = fmt.Sprintf("/notifications?page=%d", page+1)
+	}
+
+	utils.RenderTemplate(w, r, "notifications.html", map[string]interface{}{
+		"notifications": notifications,
+		"prev_link":     prevLink,
+		"next_link":     nextLink,
+	}, nil)
+}
+
+// Marks a notification as read and sends the user on to its post
+func Notification(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	notification, _ := models.GetNotification(id)
+	if notification == nil || notification.UserID != currentUser.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Reported and rejected posts have often been deleted, in which case
+	// there's nowhere else to go
+	post := notification.GetPost()
+	visible := post != nil && !post.IsDeleted()
+	if visible {
+		board, _ := models.GetBoard(int(post.BoardID))
+		visible = models.Can(currentUser, models.PermBoardRead, board)
+	}
+
+	if !visible && notification.Type != models.NotificationReport && notification.Type != models.NotificationRejected {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := notification.MarkRead(); err != nil {
+		fmt.Printf("[error] Could not mark notification read (%s)\n", err.Error())
+	}
+
+	if !visible {
+		http.Redirect(w, r, "/notifications", http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, post.GetLink(), http.StatusFound)
+}
@@ -30,12 +30,14 @@ func renderPostEditor(
 
 			return !post.ParentID.Valid
 		},
+
+		"ShowReasonField": func() bool {
+			return post != nil && post.ID != 0
+		},
 	})
 }
 
 func PostEditor(w http.ResponseWriter, r *http.Request) {
-	db := models.GetDbSession()
-
 	var err error
 	var board *models.Board
 	var post *models.Post
@@ -51,8 +53,11 @@ func PostEditor(w http.ResponseWriter, r *http.Request) {
 	post_id_str := r.FormValue("post_id")
 	if post_id_str != "" {
 		post_id, _ := strconv.Atoi(post_id_str)
-		post_tmp, _ := db.Get(&models.Post{}, post_id)
-		post = post_tmp.(*models.Post)
+		post, err = models.GetPost(post_id)
+		if post == nil || post.IsDeleted() {
+			http.NotFound(w, r)
+			return
+		}
 	}
 
 	if err != nil {
@@ -67,18 +72,39 @@ func PostEditor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if post != nil && (post.AuthorID != currentUser.ID && !currentUser.CanModerate()) {
+	if ban := getActiveBan(r, currentUser); ban != nil {
+		renderBanned(w, r, ban)
+		return
+	}
+
+	if post == nil && (board == nil || !models.Can(currentUser, models.PermPostCreate, board)) {
 		http.NotFound(w, r)
 		return
 	}
 
+	if post != nil {
+		postBoard, _ := models.GetBoard(int(post.BoardID))
+		if postBoard == nil || !models.Can(currentUser, models.PermBoardRead, postBoard) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if post.AuthorID != currentUser.ID && !models.Can(currentUser, models.PermPostEdit, postBoard) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
 	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
 		title := r.FormValue("title")
 		content := r.FormValue("content")
 
 		if post == nil {
 			post = models.NewPost(currentUser, board, title, content)
-			post.LatestReply = time.Now()
 
 			err = post.Validate()
 			if err != nil {
@@ -86,20 +112,38 @@ func PostEditor(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			err = db.Insert(post)
+			err = post.Publish()
+			if err == nil {
+				queuePostWebhook(models.WebhookThreadCreate, post, currentUser, nil)
+			}
 		} else {
-			post.Title = title
-			post.Content = content
-			post.LastEdit = time.Now()
-			post.LatestReply = time.Now()
+			before := map[string]string{"title": post.Title, "content": post.Content}
 
-			err = post.Validate()
+			// Check the new version without touching the post, as the
+			// old version still needs to go in its history
+			err = post.ValidateEdit(title, content)
 			if err != nil {
-				renderPostEditor(w, r, board, post, err)
+				edited := *post
+				edited.Title = title
+				edited.Content = content
+				renderPostEditor(w, r, board, &edited, err)
 				return
 			}
 
-			_, err = db.Update(post)
+			post.LatestReply = time.Now()
+			err = post.SaveEdit(currentUser, title, content, r.FormValue("reason"))
+			if err == nil {
+				queuePostWebhook(models.WebhookPostEdit, post, currentUser, map[string]interface{}{
+					"reason": r.FormValue("reason"),
+				})
+				publishThreadEvent(utils.ThreadEventEdit, post)
+			}
+			if err == nil && post.AuthorID != currentUser.ID {
+				newModLogEntry(r, currentUser, models.ModActionPostEdit).OnPost(post).Change(
+					before,
+					map[string]string{"title": post.Title, "content": post.Content},
+				).WithReason(r.FormValue("reason")).Save()
+			}
 		}
 
 		if err != nil {
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// One version of a post along with what changed since the version before
+type revisionDiff struct {
+	Revision *models.PostRevision
+	Number   int
+	Original bool
+	Current  bool
+	Title    []utils.DiffOp
+	Content  []utils.DiffOp
+}
+
+// Shows every version of a post and lets moderators go back to one
+func PostHistory(w http.ResponseWriter, r *http.Request) {
+	postID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	post, err := models.GetPost(postID)
+	if err != nil || post == nil || post.IsDeleted() {
+		http.NotFound(w, r)
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	board, _ := models.GetBoard(int(post.BoardID))
+	if board == nil || !models.Can(currentUser, models.PermBoardRead, board) || !post.IsVisibleTo(currentUser, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	canRollback := models.Can(currentUser, models.PermPostEdit, board)
+
+	var formError string
+	if r.Method == "POST" {
+		if !canRollback {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		revisionID, _ := strconv.Atoi(r.FormValue("revision_id"))
+		revision, _ := models.GetPostRevision(revisionID)
+		if revision == nil || revision.PostID != post.ID {
+			http.NotFound(w, r)
+			return
+		}
+
+		before := map[string]string{"title": post.Title, "content": post.Content}
+		reason := fmt.Sprintf("Rolled back to the version from %s", revision.CreatedOn.Format("Mon Jan 2 2006 15:04"))
+		if extra := r.FormValue("reason"); extra != "" {
+			reason += ": " + extra
+		}
+
+		err = post.SaveEdit(currentUser, revision.Title, revision.Content, reason)
+		if err != nil {
+			fmt.Printf("[error] Could not roll back post (%s)\n", err.Error())
+			formError = "Could not roll back the post"
+		} else {
+			newModLogEntry(r, currentUser, models.ModActionPostRollback).OnPost(post).Change(
+				before,
+				map[string]string{"title": post.Title, "content": post.Content},
+			).WithReason(reason).Save()
+			queuePostWebhook(models.WebhookPostEdit, post, currentUser, map[string]interface{}{
+				"reason": reason,
+			})
+			publishThreadEvent(utils.ThreadEventEdit, post)
+
+			http.Redirect(w, r, fmt.Sprintf("/post/%d/history", post.ID), http.StatusFound)
+			return
+		}
+	}
+
+	revisions, err := post.GetRevisions()
+	if err != nil {
+		fmt.Printf("[error] Could not get revisions (%s)\n", err.Error())
+	}
+
+	byWord := r.FormValue("mode") != "line"
+	diff := utils.DiffWords
+	if !byWord {
+		diff = utils.DiffLines
+	}
+
+	// Newest first, each compared with the one before it
+	var diffs []*revisionDiff
+	for i := len(revisions) - 1; i >= 0; i-- {
+		entry := &revisionDiff{
+			Revision: revisions[i],
+			Number:   i,
+			Original: i == 0,
+			Current:  i == len(revisions)-1,
+		}
+
+		if i == 0 {
+			entry.Title = diff("", revisions[i].Title)
+			entry.Content = diff("", revisions[i].Content)
+		} else {
+			entry.Title = diff(revisions[i-1].Title, revisions[i].Title)
+			entry.Content = diff(revisions[i-1].Content, revisions[i].Content)
+		}
+
+		diffs = append(diffs, entry)
+	}
+
+	utils.RenderTemplate(w, r, "post_history.html", map[string]interface{}{
+		"board":       board,
+		"post":        post,
+		"diffs":       diffs,
+		"byWord":      byWord,
+		"canRollback": canRollback,
+		"error":       formError,
+	}, nil)
+}
@@ -14,6 +14,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if ban := models.GetActiveIPBan(utils.GetRemoteIP(r)); ban != nil {
+		renderBanned(w, r, ban)
+		return
+	}
+
 	if r.Method == "POST" {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
@@ -43,18 +48,24 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// We're good, let's make it
-		user := models.NewUser(username, password)
-		err = db.Insert(user)
-
+		user, err := models.NewUser(username, password)
 		if err != nil {
+			fmt.Printf("[error] Could not hash password (%s)\n", err.Error())
+		} else if err = db.Insert(user); err != nil {
 			fmt.Printf("[error] Could not insert user (%s)\n", err.Error())
+		}
+
+		if err != nil {
+			utils.RenderTemplate(w, r, "register.html", map[string]interface{}{
+				"error": "Could not create your account. Please try again.",
+			}, nil)
 			return
 		}
 
 		// Adminify the first user
 		id, err := db.SelectInt("SELECT lastval()")
 		if err == nil && id == 1 {
-			user.GroupID = 2
+			user.GroupID = models.AdministratorGroupID
 			count, err = db.Update(user)
 
 			if err != nil {
@@ -63,6 +74,8 @@ func Register(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		queueUserWebhook(models.WebhookUserRegister, user)
+
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Lets a user report a post to the moderators
+func ActionReportPost(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	postID, _ := strconv.Atoi(r.FormValue("post_id"))
+	post, _ := models.GetPost(postID)
+	if post == nil || post.IsDeleted() || post.Pending || post.AuthorID == currentUser.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, _ := models.GetBoard(int(post.BoardID))
+	if !models.Can(currentUser, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if ban := getActiveBan(r, currentUser); ban != nil {
+		renderBanned(w, r, ban)
+		return
+	}
+
+	var formError error
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		formError = models.ReportPost(currentUser, post, r.FormValue("category"), r.FormValue("reason"))
+		if formError == nil {
+			http.Redirect(w, r, post.GetLink(), http.StatusFound)
+			return
+		}
+	}
+
+	utils.RenderTemplate(w, r, "action_report_post.html", map[string]interface{}{
+		"post":       post,
+		"board":      board,
+		"categories": models.ReportCategories,
+		"category":   r.FormValue("category"),
+		"reason":     r.FormValue("reason"),
+		"error":      formError,
+	}, nil)
+}
+
+// Summarises a post's reports for the mod log
+func getReportReason(reported *models.ReportedPost) string {
+	reason := "Reported for " + reported.Reports[0].Category
+	if len(reported.Reports) > 1 {
+		reason = fmt.Sprintf("%s (%d reports)", reason, len(reported.Reports))
+	}
+
+	return reason
+}
+
+// Carries out a moderator's decision on a reported post, returning the
+// status its reports are closed with
+func handleReportedPost(r *http.Request, currentUser *models.User, reported *models.ReportedPost, board *models.Board) (string, error) {
+	post := reported.Post
+	reason := getReportReason(reported)
+
+	switch r.FormValue("action") {
+	case "delete":
+		if !models.Can(currentUser, models.PermPostDelete, board) {
+			return "", errors.New("You can't delete posts here")
+		}
+		if !post.IsDeleted() {
+			if err := post.SoftDelete(currentUser, reason); err != nil {
+				return "", err
+			}
+			newModLogEntry(r, currentUser, models.ModActionPostDelete).OnPost(post).WithReason(reason).Save()
+			queuePostWebhook(models.WebhookPostDelete, post, currentUser, map[string]interface{}{
+				"reason": reason,
+			})
+		}
+		return models.ReportDeleted, nil
+
+	case "lock":
+		if !models.Can(currentUser, models.PermThreadLock, board) {
+			return "", errors.New("You can't lock threads here")
+		}
+		thread, _ := models.GetPost(int(post.GetThreadID()))
+		if thread == nil {
+			return "", errors.New("That thread no longer exists")
+		}
+		if !thread.Locked {
+			if err := thread.SetLocked(true); err != nil {
+				return "", err
+			}
+			newModLogEntry(r, currentUser, models.ModActionThreadLock).OnPost(thread).WithReason(reason).Save()
+			queuePostWebhook(models.WebhookThreadLock, thread, currentUser, map[string]interface{}{
+				"reason": reason,
+			})
+		}
+		return models.ReportLocked, nil
+
+	case "ban":
+		author, _ := models.GetUser(int(post.AuthorID))
+		if author == nil {
+			return "", errors.New("That user no longer exists")
+		}
+		if models.GetActiveUserBan(author) == nil {
+			if _, err := banUser(r, currentUser, author, "", reason); err != nil {
+				return "", err
+			}
+		}
+		return models.ReportBanned, nil
+
+	case "spam":
+		if !models.Can(currentUser, models.PermPostApprove, board) {
+			return "", errors.New("You can't mark posts as spam here")
+		}
+		if err := markPostAsSpam(r, currentUser, post); err != nil {
+			return "", err
+		}
+		return models.ReportDeleted, nil
+
+	case "not_spam":
+		if !models.Can(currentUser, models.PermPostApprove, board) {
+			return "", errors.New("You can't mark posts as not spam here")
+		}
+		if err := markPostAsNotSpam(r, currentUser, post); err != nil {
+			return "", err
+		}
+		newModLogEntry(r, currentUser, models.ModActionReportDismiss).OnPost(post).WithReason(reason).Save()
+		return models.ReportDismissed, nil
+
+	case "dismiss":
+		newModLogEntry(r, currentUser, models.ModActionReportDismiss).OnPost(post).WithReason(reason).Save()
+		return models.ReportDismissed, nil
+	}
+
+	return "", errors.New("Unknown action")
+}
+
+// The moderator queue of reported posts
+func Reports(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil || !currentUser.CanHandleReports() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var formError error
+	var notice string
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		postID, _ := strconv.Atoi(r.FormValue("post_id"))
+		post, _ := models.GetPost(postID)
+		if post == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		board, _ := models.GetBoard(int(post.BoardID))
+		if board == nil || !models.Can(currentUser, models.PermReportHandle, board) {
+			http.NotFound(w, r)
+			return
+		}
+
+		queue, err := models.GetReportQueue(currentUser)
+		if err != nil {
+			fmt.Printf("[error] Could not get reported posts (%s)\n", err.Error())
+		}
+
+		var reported *models.ReportedPost
+		for _, item := range queue {
+			if item.Post.ID == post.ID {
+				reported = item
+			}
+		}
+
+		if reported == nil {
+			formError = errors.New("Someone else has already handled that report")
+		} else {
+			var status string
+			status, formError = handleReportedPost(r, currentUser, reported, board)
+			if formError == nil {
+				formError = models.ResolvePostReports(post, currentUser, status)
+			}
+			if formError == nil {
+				notice = "Report handled: " + (&models.PostReport{Status: status}).DescribeOutcome()
+			}
+		}
+	}
+
+	queue, err := models.GetReportQueue(currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get reported posts (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "reports.html", map[string]interface{}{
+		"queue":  queue,
+		"error":  formError,
+		"notice": notice,
+	}, map[string]interface{}{
+		"CurrentUserCanOnPost": func(permission string, post *models.Post) bool {
+			board, _ := models.GetBoard(int(post.BoardID))
+			return board != nil && models.Can(currentUser, permission, board)
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Escapes a search snippet and highlights the matches in it
+func highlightSnippet(snippet string) template.HTML {
+	var out strings.Builder
+	for _, part := range strings.Split(snippet, models.SearchMatchStart) {
+		if i := strings.Index(part, models.SearchMatchStop); i != -1 {
+			out.WriteString("<mark>" + html.EscapeString(part[:i]) + "</mark>")
+			part = part[i+len(models.SearchMatchStop):]
+		}
+		out.WriteString(html.EscapeString(part))
+	}
+
+	return template.HTML(out.String())
+}
+
+func Search(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+
+	var formError string
+	query := &models.SearchQuery{
+		Terms:       r.FormValue("q"),
+		ThreadsOnly: r.FormValue("threads_only") == "1",
+	}
+
+	boardID, _ := strconv.Atoi(r.FormValue("board"))
+	query.BoardID = int64(boardID)
+
+	if name := r.FormValue("author"); name != "" {
+		if author, _ := models.GetUserByUsername(name); author != nil {
+			query.AuthorID = author.ID
+		} else {
+			formError = "There's no user called " + name
+		}
+	}
+
+	if from := r.FormValue("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			formError = "Dates should look like 2006-01-02"
+		}
+		query.From = t
+	}
+
+	// The "to" date is inclusive
+	if to := r.FormValue("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			formError = "Dates should look like 2006-01-02"
+		} else {
+			query.To = t.AddDate(0, 0, 1)
+		}
+	}
+
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 0 {
+		page = 0
+	}
+
+	var results []*models.SearchResult
+	numPages := 0
+	if formError == "" {
+		var err error
+		results, numPages, err = models.Search(query, currentUser, page)
+		if err != nil {
+			fmt.Printf("[error] Could not search (%s)\n", err.Error())
+			formError = "Something went wrong with your search"
+		}
+	}
+
+	boards, _ := models.GetReadableBoards(currentUser)
+
+	pageLink := func(page int) string {
+		values := r.URL.Query()
+		values.Set("page", strconv.Itoa(page))
+		return "/search?" + values.Encode()
+	}
+
+	utils.RenderTemplate(w, r, "search.html", map[string]interface{}{
+		"results":      results,
+		"boards":       boards,
+		"error":        formError,
+		"q":            query.Terms,
+		"author":       r.FormValue("author"),
+		"board_id":     query.BoardID,
+		"from":         r.FormValue("from"),
+		"to":           r.FormValue("to"),
+		"threads_only": query.ThreadsOnly,
+		"searched":     strings.TrimSpace(query.Terms) != "",
+		"prev_page":    page > 0,
+		"next_page":    page < numPages-1,
+		"prev_link":    pageLink(page - 1),
+		"next_link":    pageLink(page + 1),
+	}, map[string]interface{}{
+		"Highlight": highlightSnippet,
+	})
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Given as the reason when a post is deleted as spam
+const spamDeleteReason = "Spam"
+
+// Teaches the spam filter that the post is spam and moves it to the trash
+func markPostAsSpam(r *http.Request, currentUser *models.User, post *models.Post) error {
+	if err := models.MarkSpam(post, currentUser, true); err != nil {
+		return err
+	}
+
+	if !post.IsDeleted() {
+		if err := post.SoftDelete(currentUser, spamDeleteReason); err != nil {
+			return err
+		}
+		queuePostWebhook(models.WebhookPostDelete, post, currentUser, map[string]interface{}{
+			"reason": spamDeleteReason,
+		})
+	}
+
+	newModLogEntry(r, currentUser, models.ModActionPostSpam).OnPost(post).WithReason(spamDeleteReason).Save()
+	return nil
+}
+
+// Teaches the spam filter that the post isn't spam. The post is left as
+// it is.
+func markPostAsNotSpam(r *http.Request, currentUser *models.User, post *models.Post) error {
+	if err := models.MarkSpam(post, currentUser, false); err != nil {
+		return err
+	}
+
+	newModLogEntry(r, currentUser, models.ModActionPostNotSpam).OnPost(post).Save()
+	return nil
+}
+
+// Marks a post in a thread as spam, or as not spam so the filter learns
+// from posts it let through rightly
+func ActionMarkSpam(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
+	postID, _ := strconv.Atoi(r.FormValue("post_id"))
+	post, _ := models.GetPost(postID)
+	if post == nil || post.IsDeleted() || post.AuthorID == currentUser.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, _ := models.GetBoard(int(post.BoardID))
+	if board == nil || !models.Can(currentUser, models.PermPostApprove, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Posts waiting for approval are marked from the approval queue
+	if r.FormValue("verdict") == "not_spam" && !post.Pending {
+		if err := markPostAsNotSpam(r, currentUser, post); err != nil {
+			fmt.Printf("[error] Could not mark post as not spam (%s)\n", err.Error())
+			http.NotFound(w, r)
+			return
+		}
+
+		http.Redirect(w, r, post.GetLink(), http.StatusFound)
+		return
+	}
+
+	if err := markPostAsSpam(r, currentUser, post); err != nil {
+		fmt.Printf("[error] Could not mark post as spam (%s)\n", err.Error())
+		http.NotFound(w, r)
+		return
+	}
+
+	if !post.ParentID.Valid {
+		http.Redirect(w, r, fmt.Sprintf("/board/%d", post.BoardID), http.StatusFound)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", post.BoardID, post.ParentID.Int64), http.StatusFound)
+	}
+}
+
+// Shows admins how well the spam filter is doing
+func AdminSpam(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermSettingsEdit, nil) {
+		http.NotFound(w, r)
+		return
+	}
+
+	stats, err := models.GetSpamStats()
+	if err != nil {
+		fmt.Printf("[error] Could not get spam filter stats (%s)\n", err.Error())
+		http.Error(w, "Could not get spam filter stats", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := models.GetTopSpamTokens(30)
+	if err != nil {
+		fmt.Printf("[error] Could not get spam tokens (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "admin_spam.html", map[string]interface{}{
+		"stats":  stats,
+		"tokens": tokens,
+	}, nil)
+}
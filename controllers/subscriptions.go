@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Starts or stops watching a thread
+func ActionWatchThread(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetCurrentUser(r)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
+	threadID, _ := strconv.Atoi(r.FormValue("post_id"))
+	thread, err := models.GetPost(threadID)
+	if thread == nil || err != nil || thread.IsDeleted() || thread.ParentID.Valid {
+		http.NotFound(w, r)
+		return
+	}
+
+	board, _ := models.GetBoard(int(thread.BoardID))
+	if board == nil || !models.Can(user, models.PermBoardRead, board) || !thread.IsVisibleTo(user, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if user.IsWatchingThread(thread) {
+		err = user.UnwatchThread(thread)
+	} else {
+		err = user.WatchThread(thread)
+	}
+
+	if err != nil {
+		fmt.Printf("[error] Could not change thread subscription (%s)\n", err.Error())
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/board/%d/%d", thread.BoardID, thread.ID), http.StatusFound)
+}
+
+// Starts or stops watching a board
+func ActionWatchBoard(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetCurrentUser(r)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !utils.RequirePOSTWithCSRF(w, r) {
+		return
+	}
+
+	boardID, _ := strconv.Atoi(r.FormValue("board_id"))
+	board, err := models.GetBoard(boardID)
+	if board == nil || err != nil || !models.Can(user, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if user.IsWatchingBoard(board) {
+		err = user.UnwatchBoard(board)
+	} else {
+		err = user.WatchBoard(board)
+	}
+
+	if err != nil {
+		fmt.Printf("[error] Could not change board subscription (%s)\n", err.Error())
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/board/%d", board.ID), http.StatusFound)
+}
+
+// Lists the threads and boards the current user is watching
+func Subscriptions(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	threads, err := currentUser.GetWatchedThreads()
+	if err != nil {
+		fmt.Printf("[error] Could not get watched threads (%s)\n", err.Error())
+	}
+
+	boards, err := currentUser.GetWatchedBoards()
+	if err != nil {
+		fmt.Printf("[error] Could not get watched boards (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "subscriptions.html", map[string]interface{}{
+		"threads": threads,
+		"boards":  boards,
+	}, nil)
+}
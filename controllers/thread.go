@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stevenleeg/gobb/config"
@@ -25,11 +24,21 @@ func Thread(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.Atoi(mux.Vars(r)["post_id"])
 	err, op, posts := models.GetThread(postID, pageID)
 
+	// Make sure the URL's board really is the thread's board, as that's
+	// what access is checked against
+	if err != nil || board == nil || op.ParentID.Valid || op.BoardID != board.ID {
+		http.NotFound(w, r)
+		return
+	}
+
 	var postingError error
 
 	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardRead, board) || !op.IsVisibleTo(currentUser, board) {
+		http.NotFound(w, r)
+		return
+	}
 	if r.Method == "POST" {
-		db := models.GetDbSession()
 		title := r.FormValue("title")
 		content := r.FormValue("content")
 
@@ -38,23 +47,48 @@ func Thread(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if op.Locked && !currentUser.CanModerate() {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if ban := getActiveBan(r, currentUser); ban != nil {
+			renderBanned(w, r, ban)
+			return
+		}
+
+		if !models.Can(currentUser, models.PermPostReply, board) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if op.Locked && !models.Can(currentUser, models.PermThreadLock, board) {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Nobody replies to a thread until it's been approved
+		if op.Pending {
 			http.NotFound(w, r)
 			return
 		}
 
 		post := models.NewPost(currentUser, board, title, content)
 		post.ParentID = sql.NullInt64{int64(postID), true}
-		op.LatestReply = time.Now()
 
-		postingError = post.Validate()
+		postingError = post.Publish()
 
 		if postingError == nil {
-			db.Insert(post)
-			db.Update(op)
+			queuePostWebhook(models.WebhookPostReply, post, currentUser, nil)
+			publishThreadEvent(utils.ThreadEventReply, post)
+
+			if post.Pending {
+				http.Redirect(w, r, post.GetLink(), http.StatusFound)
+				return
+			}
 
 			if page := post.GetPageInThread(); page != pageID {
 				http.Redirect(w, r, fmt.Sprintf("/board/%d/%d?page=%d#post_%d", post.BoardID, op.ID, page, post.ID), http.StatusFound)
+				return
 			}
 
 			err, op, posts = models.GetThread(postID, pageID)
@@ -84,6 +118,12 @@ func Thread(w http.ResponseWriter, r *http.Request) {
 		models.AddView(currentUser, op)
 	}
 
+	// Replies waiting for approval go after the last page
+	var pendingPosts []*models.Post
+	if pageID == numPages {
+		pendingPosts = op.GetPendingReplies(currentUser, board)
+	}
+
 	utils.RenderTemplate(w, r, "thread.html", map[string]interface{}{
 		"board":        board,
 		"op":           op,
@@ -95,42 +135,38 @@ func Thread(w http.ResponseWriter, r *http.Request) {
 		"pageID":       pageID,
 		"postingError": postingError,
 		"previousText": previousText,
+		"feed_path":    fmt.Sprintf("/board/%d/%d/feed", board.ID, op.ID),
+		"feed_title":   op.Title,
+		"watching":     currentUser != nil && currentUser.IsWatchingThread(op),
+		"pendingPosts": pendingPosts,
 	}, map[string]interface{}{
 
 		"CurrentUserCanModerateThread": func(thread *models.Post) bool {
-			currentUser := utils.GetCurrentUser(r)
-			if currentUser == nil {
+			if thread.ParentID.Valid {
 				return false
 			}
 
-			return (currentUser.CanModerate() && thread.ParentID.Valid == false)
+			return models.CanAny(currentUser, board, models.PermThreadStick, models.PermThreadLock, models.PermThreadMove)
 		},
 
-		"CurrentUserCanDeletePost": func(thread *models.Post) bool {
-			currentUser := utils.GetCurrentUser(r)
-			if currentUser == nil {
-				return false
-			}
-
-			return (currentUser.ID == thread.AuthorID) || currentUser.CanModerate()
+		"CurrentUserCan": func(permission string) bool {
+			return models.Can(currentUser, permission, board)
 		},
 
-		"CurrentUserCanEditPost": func(post *models.Post) bool {
-			currentUser := utils.GetCurrentUser(r)
+		"CurrentUserCanDeletePost": func(thread *models.Post) bool {
 			if currentUser == nil {
 				return false
 			}
 
-			return (currentUser.ID == post.AuthorID || currentUser.CanModerate())
+			return (currentUser.ID == thread.AuthorID) || models.Can(currentUser, models.PermPostDelete, board)
 		},
 
-		"CurrentUserCanModerate": func() bool {
-			currentUser := utils.GetCurrentUser(r)
+		"CurrentUserCanEditPost": func(post *models.Post) bool {
 			if currentUser == nil {
 				return false
 			}
 
-			return currentUser.CanModerate()
+			return (currentUser.ID == post.AuthorID || models.Can(currentUser, models.PermPostEdit, board))
 		},
 
 		"SignaturesEnabled": func() bool {
@@ -138,12 +174,24 @@ func Thread(w http.ResponseWriter, r *http.Request) {
 			return enableSignatures
 		},
 
+		"CurrentUserCanBookmark": func() bool {
+			return currentUser != nil
+		},
+
+		"CurrentUserCanReport": func(post *models.Post) bool {
+			return currentUser != nil && post.AuthorID != currentUser.ID && !post.Pending
+		},
+
+		"CurrentUserCanMarkSpam": func(post *models.Post) bool {
+			return currentUser != nil && post.AuthorID != currentUser.ID && models.Can(currentUser, models.PermPostApprove, board)
+		},
+
 		"CurrentUserCanReply": func(post *models.Post) bool {
-			currentUser := utils.GetCurrentUser(r)
-			if currentUser != nil && (!post.Locked || currentUser.CanModerate()) {
-				return true
+			if op.Pending || !models.Can(currentUser, models.PermPostReply, board) {
+				return false
 			}
-			return false
+
+			return !post.Locked || models.Can(currentUser, models.PermThreadLock, board)
 		},
 	})
 }
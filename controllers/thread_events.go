@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// How often an idle event stream is sent a comment, so proxies don't
+// close it
+const threadEventKeepalive = 25 * time.Second
+
+// Tells everyone reading the post's thread that it was added or changed.
+// Posts waiting for approval stay quiet until they're approved.
+func publishThreadEvent(eventType string, post *models.Post) {
+	if post.Pending {
+		return
+	}
+
+	utils.PublishThreadEvent(post.GetThreadID(), &utils.ThreadEvent{
+		Type:   eventType,
+		PostID: post.ID,
+		Page:   post.GetPageInThread(),
+	})
+}
+
+// Streams new and edited posts in a thread as server-sent events. Only
+// post IDs and pages are sent; the page fetches the posts itself.
+func ThreadEvents(w http.ResponseWriter, r *http.Request) {
+	boardID, _ := strconv.Atoi(mux.Vars(r)["board_id"])
+	board, _ := models.GetBoard(boardID)
+
+	postID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	op, _ := models.GetPost(postID)
+	if board == nil || op == nil || op.IsDeleted() || op.Pending || op.ParentID.Valid || op.BoardID != board.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	currentUser := utils.GetCurrentUser(r)
+	if !models.Can(currentUser, models.PermBoardRead, board) {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := utils.SubscribeToThread(op.ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Ask the browser to wait a little before reconnecting if we go away
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(threadEventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				fmt.Printf("[error] Could not encode thread event (%s)\n", err.Error())
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Lists deleted posts and boards so moderators can restore them and
+// admins can purge them for good
+func Trash(w http.ResponseWriter, r *http.Request) {
+	currentUser := utils.GetCurrentUser(r)
+	if currentUser == nil || !currentUser.CanViewTrash() {
+		http.NotFound(w, r)
+		return
+	}
+
+	canPurge := models.Can(currentUser, models.PermTrashPurge, nil)
+	canManageBoards := models.Can(currentUser, models.PermBoardManage, nil)
+
+	var formError string
+	var notice string
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		postID, _ := strconv.Atoi(r.FormValue("post_id"))
+		boardID, _ := strconv.Atoi(r.FormValue("board_id"))
+		restore := r.FormValue("restore") != ""
+		purge := r.FormValue("purge") != ""
+
+		switch {
+		case postID != 0 && (restore || purge):
+			post, _ := models.GetPost(postID)
+			if post == nil || !post.IsDeleted() {
+				http.NotFound(w, r)
+				return
+			}
+
+			board, _ := models.GetBoard(int(post.BoardID))
+			if board == nil || !models.Can(currentUser, models.PermPostDelete, board) || (purge && !canPurge) {
+				http.NotFound(w, r)
+				return
+			}
+
+			var err error
+			if restore {
+				if err = post.Restore(); err == nil {
+					newModLogEntry(r, currentUser, models.ModActionPostRestore).OnPost(post).Save()
+					notice = "Restored"
+
+					// Bringing back a post deleted as spam says it wasn't
+					if verdict := models.GetSpamVerdict(post); verdict != nil && verdict.Spam {
+						if err := models.MarkSpam(post, currentUser, false); err != nil {
+							fmt.Printf("[error] Could not teach the spam filter (%s)\n", err.Error())
+						}
+					}
+				}
+			} else {
+				entry := newModLogEntry(r, currentUser, models.ModActionPostPurge).OnPost(post).Change(map[string]interface{}{
+					"title":   post.Title,
+					"content": post.Content,
+				}, nil)
+				if err = post.Purge(); err == nil {
+					entry.Save()
+					notice = "Purged"
+				}
+			}
+
+			if err != nil {
+				formError = err.Error()
+			}
+
+		case boardID != 0 && (restore || purge):
+			board, _ := models.GetBoard(boardID)
+			if board == nil || !board.IsDeleted() || !canManageBoards || (purge && !canPurge) {
+				http.NotFound(w, r)
+				return
+			}
+
+			var err error
+			if restore {
+				if err = board.Restore(); err == nil {
+					newModLogEntry(r, currentUser, models.ModActionBoardRestore).OnBoard(board).Save()
+					notice = "Restored"
+				}
+			} else {
+				entry := newModLogEntry(r, currentUser, models.ModActionBoardPurge).OnBoard(board).Change(getBoardLogFields(board), nil)
+				if err = board.Purge(); err == nil {
+					entry.Save()
+					notice = "Purged"
+				}
+			}
+
+			if err != nil {
+				formError = err.Error()
+			}
+
+		case r.FormValue("purge_older") != "" && canPurge:
+			days, _ := strconv.Atoi(r.FormValue("days"))
+			if days < 0 {
+				days = 0
+			}
+
+			purged, err := models.PurgeTrashBefore(time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				fmt.Printf("[error] Could not empty the trash (%s)\n", err.Error())
+				formError = "Could not empty the trash"
+			} else {
+				newModLogEntry(r, currentUser, models.ModActionTrashPurge).OnTrash().Change(nil, map[string]int{
+					"older_than_days": days,
+					"purged":          purged,
+				}).Save()
+				notice = fmt.Sprintf("Purged %d items", purged)
+			}
+
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	posts, err := models.GetTrashedPosts(currentUser)
+	if err != nil {
+		fmt.Printf("[error] Could not get trashed posts (%s)\n", err.Error())
+	}
+
+	var boards []*models.Board
+	if canManageBoards {
+		boards, _ = models.GetTrashedBoards()
+	}
+
+	utils.RenderTemplate(w, r, "trash.html", map[string]interface{}{
+		"posts":           posts,
+		"boards":          boards,
+		"canPurge":        canPurge,
+		"canManageBoards": canManageBoards,
+		"retentionDays":   int(models.GetTrashRetention().Hours() / 24),
+		"error":           formError,
+		"notice":          notice,
+	}, nil)
+}
@@ -1,17 +1,17 @@
 package controllers
 
 import (
+	"html/template"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/config"
 	"github.com/stevenleeg/gobb/models"
 	"github.com/stevenleeg/gobb/utils"
 )
 
 func User(w http.ResponseWriter, r *http.Request) {
-	db := models.GetDbSession()
-
 	userID, err := strconv.Atoi(mux.Vars(r)["id"])
 
 	if err != nil {
@@ -19,13 +19,72 @@ func User(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := db.Get(&models.User{}, userID)
-	if err != nil {
+	user, err := models.GetUser(userID)
+	if err != nil || user == nil {
 		http.NotFound(w, r)
 		return
 	}
 
+	// Leave out posts on boards the visitor can't see
+	currentUser := utils.GetCurrentUser(r)
+	posts := user.GetPosts(0, currentUser)
+
 	utils.RenderTemplate(w, r, "user.html", map[string]interface{}{
-		"user": user,
-	}, nil)
+		"user":  user,
+		"posts": posts,
+	}, getProfilePostFuncs(currentUser))
+}
+
+// The per-post checks the profile's post list needs. Unlike a thread, the
+// posts come from any number of boards, so each is checked against its own.
+// Replying happens on the thread page, so there's no reply link here.
+func getProfilePostFuncs(currentUser *models.User) template.FuncMap {
+	boards := map[int64]*models.Board{}
+	getBoard := func(post *models.Post) *models.Board {
+		board, ok := boards[post.BoardID]
+		if !ok {
+			board, _ = models.GetBoard(int(post.BoardID))
+			boards[post.BoardID] = board
+		}
+
+		return board
+	}
+
+	return template.FuncMap{
+		"CurrentUserCanModerateThread": func(thread *models.Post) bool {
+			board := getBoard(thread)
+			if thread.ParentID.Valid || board == nil {
+				return false
+			}
+
+			return models.CanAny(currentUser, board, models.PermThreadStick, models.PermThreadLock, models.PermThreadMove)
+		},
+
+		"CurrentUserCanDeletePost": func(post *models.Post) bool {
+			board := getBoard(post)
+			if currentUser == nil || board == nil {
+				return false
+			}
+
+			return (currentUser.ID == post.AuthorID) || models.Can(currentUser, models.PermPostDelete, board)
+		},
+
+		"CurrentUserCanEditPost": func(post *models.Post) bool {
+			board := getBoard(post)
+			if currentUser == nil || board == nil {
+				return false
+			}
+
+			return (currentUser.ID == post.AuthorID || models.Can(currentUser, models.PermPostEdit, board))
+		},
+
+		"CurrentUserCanReply": func(post *models.Post) bool {
+			return false
+		},
+
+		"SignaturesEnabled": func() bool {
+			enableSignatures, _ := config.Config.GetBool("gobb", "enable_signatures")
+			return enableSignatures
+		},
+	}
 }
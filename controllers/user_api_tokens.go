@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Lets users create and revoke the tokens their scripts use to talk to
+// the API. Admins can see and revoke anyone's tokens, but only the owner
+// can create them.
+func UserAPITokens(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	currentUser := utils.GetCurrentUser(r)
+
+	if currentUser == nil || (int64(userID) != currentUser.ID && !models.Can(currentUser, models.PermUserManage, nil)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := models.GetUser(userID)
+	if err != nil || user == nil {
+		http.NotFound(w, r)
+		return
+	}
+	isOwner := user.ID == currentUser.ID
+
+	// Boards a board scoped token could post in
+	var boards []*models.Board
+	if isOwner {
+		readable, _ := models.GetReadableBoards(currentUser)
+		for _, board := range readable {
+			if models.CanAny(currentUser, board, models.PermPostCreate, models.PermPostReply) {
+				boards = append(boards, board)
+			}
+		}
+	}
+
+	var formError, newToken string
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if id, _ := strconv.Atoi(r.FormValue("token_id")); id != 0 {
+			token, _ := models.GetAPIToken(id)
+			if token == nil || token.UserID != user.ID {
+				http.NotFound(w, r)
+				return
+			}
+
+			if err := token.Revoke(); err != nil {
+				fmt.Printf("[error] Could not revoke API token (%s)\n", err.Error())
+			} else if !isOwner {
+				newModLogEntry(r, currentUser, models.ModActionTokenRevoke).OnUser(user).Change(
+					map[string]string{"token": token.Name},
+					nil,
+				).Save()
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/user/%d/settings/tokens", user.ID), http.StatusFound)
+			return
+		}
+
+		if !isOwner {
+			http.NotFound(w, r)
+			return
+		}
+
+		var board *models.Board
+		scope := r.FormValue("scope")
+		if scope == models.TokenScopeBoard {
+			boardID, _ := strconv.Atoi(r.FormValue("board_id"))
+			for _, candidate := range boards {
+				if candidate.ID == int64(boardID) {
+					board = candidate
+				}
+			}
+		}
+
+		expiry, err := models.ParseAPITokenExpiry(r.FormValue("expires_in"))
+		if err == nil {
+			_, newToken, err = models.NewAPIToken(user, r.FormValue("name"), scope, board, expiry)
+		}
+		if err != nil {
+			formError = err.Error()
+		}
+	}
+
+	tokens, err := user.GetAPITokens()
+	if err != nil {
+		fmt.Printf("[error] Could not get API tokens (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "user_api_tokens.html", map[string]interface{}{
+		"user":      user,
+		"tokens":    tokens,
+		"boards":    boards,
+		"is_owner":  isOwner,
+		"new_token": newToken,
+		"error":     formError,
+	}, nil)
+}
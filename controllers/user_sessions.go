@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Lists the places a user is logged in and lets them sign out of any of
+// them. Admins can view and revoke the sessions of any user.
+func UserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	currentUser := utils.GetCurrentUser(r)
+
+	if currentUser == nil || (int64(userID) != currentUser.ID && !models.Can(currentUser, models.PermUserManage, nil)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := models.GetUser(userID)
+	if err != nil || user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var currentSessionID int64
+	if current := utils.GetCurrentSession(r); current != nil && user.ID == currentUser.ID {
+		currentSessionID = current.ID
+	}
+
+	if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		revoked := false
+		if r.FormValue("revoke_others") != "" {
+			err = user.RevokeOtherSessions(currentSessionID)
+			revoked = true
+		} else if id, _ := strconv.Atoi(r.FormValue("session_id")); id != 0 {
+			session, _ := models.GetSession(id)
+			if session == nil || session.UserID != user.ID {
+				http.NotFound(w, r)
+				return
+			}
+
+			err = session.Revoke()
+			revoked = true
+			if session.ID == currentSessionID {
+				utils.EndSession(w, r)
+				http.Redirect(w, r, "/", http.StatusFound)
+				return
+			}
+		}
+
+		if err != nil {
+			fmt.Printf("[error] Could not revoke sessions (%s)\n", err.Error())
+		} else if revoked && user.ID != currentUser.ID {
+			newModLogEntry(r, currentUser, models.ModActionSessionRevoke).OnUser(user).Save()
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/user/%d/settings/sessions", user.ID), http.StatusFound)
+		return
+	}
+
+	sessions, err := user.GetSessions()
+	if err != nil {
+		fmt.Printf("[error] Could not get sessions (%s)\n", err.Error())
+	}
+
+	utils.RenderTemplate(w, r, "user_sessions.html", map[string]interface{}{
+		"user":     user,
+		"sessions": sessions,
+	}, map[string]interface{}{
+		"IsCurrentSession": func(session *models.Session) bool {
+			return session.ID == currentSessionID
+		},
+	})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -22,10 +23,41 @@ func UserSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	emailEnabled := utils.EmailEnabled()
+
 	success := false
 	var formError string
-	if r.Method == "POST" {
+	if r.Method == "POST" && r.FormValue("resend_verification") != "" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
+		if emailEnabled && currentUser.Email != "" && !currentUser.EmailVerified {
+			if err := currentUser.CanResendVerification(); err != nil {
+				formError = err.Error()
+			} else {
+				token := currentUser.NewEmailToken()
+				_, err := models.GetDbSession().Update(currentUser)
+				if err == nil {
+					err = utils.QueueVerificationEmail(currentUser, token)
+				}
+				if err != nil {
+					fmt.Printf("[error] Could not resend verification email (%s)\n", err.Error())
+				}
+			}
+		}
+
+		if formError == "" {
+			http.Redirect(w, r, fmt.Sprintf("/user/%d/settings", currentUser.ID), http.StatusFound)
+			return
+		}
+	} else if r.Method == "POST" {
+		if !utils.RequireCSRF(w, r) {
+			return
+		}
+
 		db := models.GetDbSession()
+		previousSignature := currentUser.Signature.String
 		currentUser.Avatar = r.FormValue("avatar_url")
 		currentUser.UserTitle = r.FormValue("user_title")
 		currentUser.StylesheetURL = sql.NullString{
@@ -50,10 +82,41 @@ func UserSettings(w http.ResponseWriter, r *http.Request) {
 			currentUser.HideOnline = true
 		}
 
+		currentUser.NotifyMentions = r.FormValue("notify_mentions") == "1"
+		currentUser.NotifyQuotes = r.FormValue("notify_quotes") == "1"
+		currentUser.NotifyReplies = r.FormValue("notify_replies") == "1"
+
+		var emailToken string
+		if emailEnabled {
+			var err error
+			emailToken, err = currentUser.SetEmail(r.FormValue("email"))
+			if err != nil {
+				formError = err.Error()
+			}
+
+			currentUser.EmailNotifications = r.FormValue("email_notifications") == "1"
+			switch digest := r.FormValue("email_digest"); digest {
+			case models.DigestDaily, models.DigestWeekly:
+				currentUser.EmailDigest = digest
+			default:
+				currentUser.EmailDigest = models.DigestOff
+			}
+		}
+
+		// Only allow safe URL schemes for things we embed in pages
+		if err := utils.ValidateURL(currentUser.Avatar); err != nil {
+			formError = "Invalid avatar URL: " + err.Error()
+		} else if err := utils.ValidateURL(currentUser.StylesheetURL.String); err != nil {
+			formError = "Invalid stylesheet URL: " + err.Error()
+		} else if currentUser.Signature.Valid && currentUser.Signature.String != previousSignature && currentUser.IsSpamSignature(currentUser.Signature.String) {
+			formError = "Your signature looks like spam to our filter. Try changing it, or ask a moderator for help."
+		}
+
 		// Update password?
 		old_pass := r.FormValue("password_old")
 		new_pass := r.FormValue("password_new")
 		new_pass2 := r.FormValue("password_new2")
+		passwordChanged := false
 		if old_pass != "" {
 			user, err := models.AuthenticateUser(currentUser.Username, old_pass)
 			if user == nil || err != nil {
@@ -62,17 +125,27 @@ func UserSettings(w http.ResponseWriter, r *http.Request) {
 				formError = "Password must be greater than 4 characters"
 			} else if new_pass != new_pass2 {
 				formError = "Passwords didn't match"
+			} else if err := currentUser.SetPassword(new_pass); err != nil {
+				formError = "Could not set password"
 			} else {
-				currentUser.SetPassword(new_pass)
-				session, _ := utils.GetCookieStore(r).Get(r, "sirsid")
-				session.Values["password"] = new_pass
-				session.Save(r, w)
+				passwordChanged = true
 			}
 		}
 
 		if formError == "" {
 			db.Update(currentUser)
 			success = true
+
+			if emailToken != "" {
+				if err := utils.QueueVerificationEmail(currentUser, emailToken); err != nil {
+					fmt.Printf("[error] Could not queue verification email (%s)\n", err.Error())
+				}
+			}
+
+			// Sign out every other device still using the old password
+			if session := utils.GetCurrentSession(r); passwordChanged && session != nil {
+				currentUser.RevokeOtherSessions(session.ID)
+			}
 		}
 	}
 
@@ -91,5 +164,6 @@ func UserSettings(w http.ResponseWriter, r *http.Request) {
 		"user_stylesheet":   stylesheet,
 		"user_signature":    signature,
 		"enable_signatures": enableSignatures,
+		"email_enabled":     emailEnabled,
 	}, nil)
 }
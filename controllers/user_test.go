@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stevenleeg/gobb/config"
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// Parses the profile page with the funcs User gives it, for a visitor
+// who isn't logged in
+func parseProfileTemplate(t *testing.T) *template.Template {
+	if utils.Store == nil {
+		utils.Store = sessions.NewCookieStore([]byte("test"))
+	}
+
+	r := httptest.NewRequest("GET", "/user/1", nil)
+	tpl, err := utils.ParseTemplate(r, "../templates", "user.html", getProfilePostFuncs(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tpl
+}
+
+func TestProfileTemplateParses(t *testing.T) {
+	parseProfileTemplate(t)
+}
+
+func TestProfileRenders(t *testing.T) {
+	path := os.Getenv("GOBB_TEST_CONFIG")
+	if path == "" {
+		t.Skip("GOBB_TEST_CONFIG isn't set")
+	}
+	config.GetConfig(path)
+	db := models.GetDbSession()
+	if db == nil {
+		t.Fatal("Could not connect to the test database")
+	}
+
+	user, err := models.NewUser(fmt.Sprintf("profile%d", time.Now().UnixNano()), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	board := models.NewBoard("Test board", "", 0)
+	if err = db.Insert(board); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		board.Purge()
+		db.Delete(user)
+	})
+
+	post := models.NewPost(user, board, "Title", "Posted from the profile test")
+	if err = db.Insert(post); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = parseProfileTemplate(t).ExecuteTemplate(&out, "user.html", map[string]interface{}{
+		"user":  user,
+		"posts": user.GetPosts(0, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "Posted from the profile test") {
+		t.Error("The profile doesn't list the user's posts")
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stevenleeg/gobb/models"
+	"github.com/stevenleeg/gobb/utils"
+)
+
+// The shapes of things sent in webhook payloads. Links are absolute so
+// receivers can post them straight into chat.
+type webhookUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	URL      string `json:"url"`
+}
+
+type webhookBoard struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type webhookPost struct {
+	ID          int64        `json:"id"`
+	ThreadID    int64        `json:"thread_id"`
+	Title       string       `json:"title,omitempty"`
+	Author      *webhookUser `json:"author"`
+	Content     string       `json:"content"`
+	ContentHTML string       `json:"content_html"`
+	CreatedOn   time.Time    `json:"created_on"`
+	URL         string       `json:"url"`
+}
+
+func newWebhookUser(user *models.User) *webhookUser {
+	if user == nil {
+		return nil
+	}
+
+	return &webhookUser{
+		ID:       user.ID,
+		Username: user.Username,
+		URL:      utils.AbsoluteURL(fmt.Sprintf("/user/%d", user.ID)),
+	}
+}
+
+// Boards guests can't read come out as nil, so that a thread moved out of
+// one doesn't give it away
+func newWebhookBoard(board *models.Board) *webhookBoard {
+	if board == nil || !models.Can(nil, models.PermBoardRead, board) {
+		return nil
+	}
+
+	return &webhookBoard{
+		ID:    board.ID,
+		Title: board.Title,
+		URL:   utils.AbsoluteURL(fmt.Sprintf("/board/%d", board.ID)),
+	}
+}
+
+func newWebhookPost(post *models.Post) *webhookPost {
+	author := post.Author
+	if author == nil {
+		author, _ = models.GetUser(int(post.AuthorID))
+	}
+
+	return &webhookPost{
+		ID:          post.ID,
+		ThreadID:    post.GetThreadID(),
+		Title:       post.Title,
+		Author:      newWebhookUser(author),
+		Content:     post.Content,
+		ContentHTML: utils.RenderMarkdown(post.Content),
+		CreatedOn:   post.CreatedOn,
+		URL:         utils.AbsoluteURL(post.GetLink()),
+	}
+}
+
+// Queues an event about a post for every webhook that wants it. The actor
+// is whoever caused the event, which isn't always the author. Anything in
+// extra is added to the payload as is. Nothing is sent about posts
+// waiting for approval; they're announced once they're approved. Webhooks
+// go to places anyone can read, so nothing is sent about posts on boards
+// guests can't read either.
+func queuePostWebhook(event string, post *models.Post, actor *models.User, extra map[string]interface{}) {
+	if post.Pending {
+		return
+	}
+
+	board, _ := models.GetBoard(int(post.BoardID))
+	if board == nil || !models.Can(nil, models.PermBoardRead, board) {
+		return
+	}
+
+	data := map[string]interface{}{
+		"post":  newWebhookPost(post),
+		"board": newWebhookBoard(board),
+		"actor": newWebhookUser(actor),
+	}
+
+	if post.ParentID.Valid {
+		if thread, _ := models.GetPost(int(post.ParentID.Int64)); thread != nil {
+			data["thread"] = newWebhookPost(thread)
+		}
+	}
+
+	for key, value := range extra {
+		data[key] = value
+	}
+
+	// The payload is built here, but the deliveries are written to the
+	// database off the request
+	go models.QueueWebhookEvent(event, data)
+}
+
+func queueUserWebhook(event string, user *models.User) {
+	data := map[string]interface{}{
+		"user": newWebhookUser(user),
+	}
+
+	go models.QueueWebhookEvent(event, data)
+}
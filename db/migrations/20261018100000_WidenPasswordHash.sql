@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+ALTER TABLE users ALTER COLUMN salt SET DEFAULT '';
+
+-- +goose Down
+-- The password column is left wide on purpose. argon2id and bcrypt hashes
+-- don't fit in the old 75 characters, so narrowing it would fail as soon
+-- as anyone has logged in since the upgrade.
+ALTER TABLE users ALTER COLUMN salt DROP DEFAULT;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS sessions (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    token       VARCHAR(64) UNIQUE NOT NULL,
+    created_on  TIMESTAMP NOT NULL,
+    last_used   TIMESTAMP NOT NULL,
+    expires_on  TIMESTAMP NOT NULL,
+    user_agent  VARCHAR(255) NOT NULL DEFAULT '',
+    ip          VARCHAR(45) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE sessions;
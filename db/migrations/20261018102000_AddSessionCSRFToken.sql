@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN csrf_token VARCHAR(64) NOT NULL DEFAULT md5(random()::text);
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN IF EXISTS csrf_token;
@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS groups (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(40) UNIQUE NOT NULL,
+    description VARCHAR(140) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name        VARCHAR(40) PRIMARY KEY,
+    description VARCHAR(140) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS group_permissions (
+    group_id    INTEGER REFERENCES groups(id) ON DELETE CASCADE NOT NULL,
+    permission  VARCHAR(40) REFERENCES permissions(name) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (group_id, permission)
+);
+
+-- The old magic group numbers become real groups with the same IDs
+INSERT INTO groups (id, name, description) VALUES
+    (0, 'User', 'Everyone who registers starts here'),
+    (1, 'Moderator', 'Keeps threads tidy on every board'),
+    (2, 'Administrator', 'Can do everything');
+SELECT setval('groups_id_seq', 3, false);
+
+INSERT INTO permissions (name, description) VALUES
+    ('post.create', 'Start new threads'),
+    ('post.reply', 'Reply to threads'),
+    ('post.edit', 'Edit posts written by other users'),
+    ('post.delete', 'Delete posts written by other users'),
+    ('thread.stick', 'Stick and unstick threads'),
+    ('thread.lock', 'Lock and unlock threads, and reply to locked threads'),
+    ('thread.move', 'Move threads between boards'),
+    ('board.manage', 'Create, edit and delete boards'),
+    ('user.manage', 'Edit other users'' profiles and sessions'),
+    ('user.ban', 'Ban users'),
+    ('group.manage', 'Create groups and change their permissions'),
+    ('settings.edit', 'Change the site''s general settings');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (0, 'post.create'), (0, 'post.reply'),
+    (1, 'post.create'), (1, 'post.reply'), (1, 'post.edit'), (1, 'post.delete'),
+    (1, 'thread.stick'), (1, 'thread.lock'), (1, 'thread.move'), (1, 'user.ban');
+
+INSERT INTO group_permissions (group_id, permission)
+    SELECT 2, name FROM permissions;
+
+UPDATE users SET group_id=0 WHERE group_id IS NULL OR group_id NOT IN (0, 1, 2);
+ALTER TABLE users ALTER COLUMN group_id SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_group_id_fkey FOREIGN KEY (group_id) REFERENCES groups(id);
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_group_id_fkey;
+ALTER TABLE users ALTER COLUMN group_id DROP NOT NULL;
+DROP TABLE group_permissions;
+DROP TABLE permissions;
+DROP TABLE groups;
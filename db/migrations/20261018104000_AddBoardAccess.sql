@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE boards ADD COLUMN guest_read BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE boards ADD COLUMN default_read BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE boards ADD COLUMN default_post BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE boards ADD COLUMN default_reply BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS board_access (
+    board_id    INTEGER REFERENCES boards(id) ON DELETE CASCADE NOT NULL,
+    group_id    INTEGER REFERENCES groups(id) ON DELETE CASCADE NOT NULL,
+    can_read    BOOLEAN NOT NULL DEFAULT TRUE,
+    can_post    BOOLEAN NOT NULL DEFAULT TRUE,
+    can_reply   BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (board_id, group_id)
+);
+
+-- +goose Down
+DROP TABLE board_access;
+ALTER TABLE boards DROP COLUMN IF EXISTS guest_read;
+ALTER TABLE boards DROP COLUMN IF EXISTS default_read;
+ALTER TABLE boards DROP COLUMN IF EXISTS default_post;
+ALTER TABLE boards DROP COLUMN IF EXISTS default_reply;
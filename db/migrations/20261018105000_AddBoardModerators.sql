@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS board_moderators (
+    board_id    INTEGER REFERENCES boards(id) ON DELETE CASCADE NOT NULL,
+    user_id     INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (board_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE board_moderators;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS bans (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    ip_range    CIDR,
+    reason      VARCHAR(255) NOT NULL,
+    created_on  TIMESTAMP NOT NULL,
+    expires_on  TIMESTAMP,
+    banned_by   INTEGER REFERENCES users(id) NOT NULL,
+    lifted_on   TIMESTAMP,
+    lifted_by   INTEGER REFERENCES users(id),
+    CHECK (user_id IS NOT NULL OR ip_range IS NOT NULL)
+);
+
+CREATE INDEX bans_user_id_idx ON bans (user_id);
+
+-- +goose Down
+DROP TABLE bans;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS mod_log (
+    id              SERIAL PRIMARY KEY,
+    actor_id        INTEGER REFERENCES users(id) NOT NULL,
+    action          VARCHAR(40) NOT NULL,
+    target_type     VARCHAR(20) NOT NULL,
+    target_id       INTEGER,
+    target_user_id  INTEGER,
+    board_id        INTEGER,
+    before_value    TEXT,
+    after_value     TEXT,
+    reason          VARCHAR(255) NOT NULL DEFAULT '',
+    ip              VARCHAR(45) NOT NULL DEFAULT '',
+    created_on      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX mod_log_created_on_idx ON mod_log (created_on);
+CREATE INDEX mod_log_actor_id_idx ON mod_log (actor_id);
+CREATE INDEX mod_log_target_user_id_idx ON mod_log (target_user_id);
+CREATE INDEX mod_log_board_id_idx ON mod_log (board_id);
+
+-- The log is append-only: entries can't be changed or removed through SQL
+CREATE RULE mod_log_no_update AS ON UPDATE TO mod_log DO INSTEAD NOTHING;
+CREATE RULE mod_log_no_delete AS ON DELETE TO mod_log DO INSTEAD NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('modlog.view', 'Read the moderation log');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'modlog.view');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='modlog.view';
+DROP TABLE mod_log;
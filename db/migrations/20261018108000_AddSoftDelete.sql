@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE posts ADD COLUMN delete_reason VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX posts_deleted_at_idx ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE boards ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE boards ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE boards ADD COLUMN delete_reason VARCHAR(255) NOT NULL DEFAULT '';
+
+INSERT INTO permissions (name, description) VALUES
+    ('trash.purge', 'Permanently delete posts and boards in the trash');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'trash.purge');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='trash.purge';
+ALTER TABLE boards DROP COLUMN delete_reason;
+ALTER TABLE boards DROP COLUMN deleted_by;
+ALTER TABLE boards DROP COLUMN deleted_at;
+DROP INDEX posts_deleted_at_idx;
+ALTER TABLE posts DROP COLUMN delete_reason;
+ALTER TABLE posts DROP COLUMN deleted_by;
+ALTER TABLE posts DROP COLUMN deleted_at;
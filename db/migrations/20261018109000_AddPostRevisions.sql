@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id              SERIAL PRIMARY KEY,
+    post_id         INTEGER REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    editor_id       INTEGER REFERENCES users(id) NOT NULL,
+    title           VARCHAR(70) NOT NULL DEFAULT '',
+    content         TEXT NOT NULL,
+    reason          VARCHAR(255) NOT NULL DEFAULT '',
+    by_moderator    BOOLEAN NOT NULL DEFAULT false,
+    created_on      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id);
+
+-- +goose Down
+DROP TABLE post_revisions;
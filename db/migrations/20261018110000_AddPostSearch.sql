@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS post_search (
+    post_id         INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    search_vector   TSVECTOR NOT NULL
+);
+
+CREATE INDEX post_search_vector_idx ON post_search USING GIN (search_vector);
+
+INSERT INTO post_search (post_id, search_vector)
+    SELECT id, setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+    FROM posts;
+
+-- +goose Down
+DROP TABLE post_search;
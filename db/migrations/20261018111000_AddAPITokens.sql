@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    name            VARCHAR(64) NOT NULL,
+    token           VARCHAR(64) UNIQUE NOT NULL,
+    scope           VARCHAR(16) NOT NULL,
+    board_id        INTEGER REFERENCES boards(id) ON DELETE CASCADE,
+    created_on      TIMESTAMP NOT NULL,
+    last_used       TIMESTAMP,
+    last_used_ip    VARCHAR(45) NOT NULL DEFAULT '',
+    expires_on      TIMESTAMP,
+    CHECK (scope != 'board' OR board_id IS NOT NULL)
+);
+
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id);
+
+-- +goose Down
+DROP TABLE api_tokens;
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS webhooks (
+    id              SERIAL PRIMARY KEY,
+    url             VARCHAR(2048) NOT NULL,
+    secret          VARCHAR(128) NOT NULL,
+    events          TEXT NOT NULL DEFAULT '',
+    active          BOOLEAN NOT NULL DEFAULT true,
+    created_by      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_on      TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              SERIAL PRIMARY KEY,
+    webhook_id      INTEGER REFERENCES webhooks(id) ON DELETE CASCADE NOT NULL,
+    event           VARCHAR(32) NOT NULL,
+    payload         TEXT NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt    TIMESTAMP NOT NULL,
+    last_attempt    TIMESTAMP,
+    response_code   INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    created_on      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_on);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt) WHERE status='pending';
+
+INSERT INTO permissions (name, description) VALUES
+    ('webhooks.manage', 'Add and remove webhooks and view their deliveries');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'webhooks.manage');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='webhooks.manage';
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notifications (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    actor_id        INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    post_id         INTEGER REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    type            VARCHAR(16) NOT NULL,
+    read            BOOLEAN NOT NULL DEFAULT FALSE,
+    created_on      TIMESTAMP NOT NULL,
+    UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, read, created_on);
+
+ALTER TABLE users ADD COLUMN notify_mentions BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN notify_quotes BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN notify_replies BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+DROP TABLE notifications;
+ALTER TABLE users DROP COLUMN notify_mentions;
+ALTER TABLE users DROP COLUMN notify_quotes;
+ALTER TABLE users DROP COLUMN notify_replies;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS thread_subscriptions (
+    thread_id       INTEGER REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_on      TIMESTAMP NOT NULL,
+    PRIMARY KEY (thread_id, user_id)
+);
+
+CREATE INDEX thread_subscriptions_user_id_idx ON thread_subscriptions (user_id);
+
+CREATE TABLE IF NOT EXISTS board_subscriptions (
+    board_id        INTEGER REFERENCES boards(id) ON DELETE CASCADE NOT NULL,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_on      TIMESTAMP NOT NULL,
+    PRIMARY KEY (board_id, user_id)
+);
+
+CREATE INDEX board_subscriptions_user_id_idx ON board_subscriptions (user_id);
+
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    post_id         INTEGER REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    note            VARCHAR(255) NOT NULL DEFAULT '',
+    created_on      TIMESTAMP NOT NULL,
+    UNIQUE (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE thread_subscriptions;
+DROP TABLE board_subscriptions;
+DROP TABLE bookmarks;
@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email VARCHAR(254) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN email_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_notifications BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN email_digest VARCHAR(8) NOT NULL DEFAULT 'off';
+ALTER TABLE users ADD COLUMN last_digest TIMESTAMP;
+ALTER TABLE users ADD COLUMN unsubscribe_token VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Notifications from before email existed shouldn't all be sent at once
+ALTER TABLE notifications ADD COLUMN emailed BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE notifications SET emailed=TRUE;
+
+CREATE TABLE IF NOT EXISTS emails (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    to_address      VARCHAR(254) NOT NULL,
+    subject         VARCHAR(255) NOT NULL,
+    body            TEXT NOT NULL,
+    unsubscribe_url VARCHAR(2048) NOT NULL DEFAULT '',
+    status          VARCHAR(16) NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt    TIMESTAMP NOT NULL,
+    last_attempt    TIMESTAMP,
+    error           TEXT NOT NULL DEFAULT '',
+    created_on      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX emails_due_idx ON emails (status, next_attempt);
+
+-- +goose Down
+DROP TABLE emails;
+ALTER TABLE notifications DROP COLUMN emailed;
+ALTER TABLE users DROP COLUMN email;
+ALTER TABLE users DROP COLUMN email_verified;
+ALTER TABLE users DROP COLUMN email_token;
+ALTER TABLE users DROP COLUMN email_notifications;
+ALTER TABLE users DROP COLUMN email_digest;
+ALTER TABLE users DROP COLUMN last_digest;
+ALTER TABLE users DROP COLUMN unsubscribe_token;
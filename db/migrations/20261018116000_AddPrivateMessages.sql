@@ -0,0 +1,68 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS conversations (
+    id              SERIAL PRIMARY KEY,
+    subject         VARCHAR(255) NOT NULL,
+    created_by      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_on      TIMESTAMP NOT NULL,
+    latest_message  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE NOT NULL,
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    joined_on       TIMESTAMP NOT NULL,
+    last_read       TIMESTAMP,
+    left_on         TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id              SERIAL PRIMARY KEY,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE NOT NULL,
+    author_id       INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    content         TEXT NOT NULL,
+    created_on      TIMESTAMP NOT NULL,
+    removed_by      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    removed_on      TIMESTAMP
+);
+
+CREATE INDEX messages_conversation_id_idx ON messages (conversation_id, created_on);
+CREATE INDEX messages_author_id_idx ON messages (author_id, created_on);
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    user_id         INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    blocked_id      INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created_on      TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, blocked_id)
+);
+
+-- Reported messages are the only ones admins can read
+CREATE TABLE IF NOT EXISTS message_reports (
+    id              SERIAL PRIMARY KEY,
+    message_id      INTEGER REFERENCES messages(id) ON DELETE CASCADE NOT NULL,
+    reporter_id     INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    reason          VARCHAR(1024) NOT NULL DEFAULT '',
+    status          VARCHAR(16) NOT NULL,
+    handled_by      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    handled_on      TIMESTAMP,
+    created_on      TIMESTAMP NOT NULL,
+    UNIQUE (message_id, reporter_id)
+);
+
+CREATE INDEX message_reports_status_idx ON message_reports (status, created_on);
+
+INSERT INTO permissions (name, description) VALUES
+    ('messages.moderate', 'Review reported private messages and remove them');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'messages.moderate');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='messages.moderate';
+DROP TABLE message_reports;
+DROP TABLE user_blocks;
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS post_reports (
+    id              SERIAL PRIMARY KEY,
+    post_id         INTEGER REFERENCES posts(id) ON DELETE CASCADE NOT NULL,
+    reporter_id     INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    category        VARCHAR(32) NOT NULL,
+    reason          VARCHAR(1024) NOT NULL DEFAULT '',
+    status          VARCHAR(16) NOT NULL,
+    handled_by      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    handled_on      TIMESTAMP,
+    created_on      TIMESTAMP NOT NULL,
+    UNIQUE (post_id, reporter_id)
+);
+
+CREATE INDEX post_reports_status_idx ON post_reports (status, created_on);
+
+-- Reporters are told when their report is handled. Those notifications
+-- point at the report, and there can be more than one per post.
+ALTER TABLE notifications ADD COLUMN report_id INTEGER REFERENCES post_reports(id) ON DELETE CASCADE;
+ALTER TABLE notifications DROP CONSTRAINT notifications_user_id_post_id_key;
+CREATE UNIQUE INDEX notifications_user_id_post_id_key ON notifications (user_id, post_id) WHERE report_id IS NULL;
+
+INSERT INTO permissions (name, description) VALUES
+    ('reports.handle', 'See reported posts and act on the reports');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'reports.handle');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='reports.handle';
+DELETE FROM notifications WHERE report_id IS NOT NULL;
+DROP INDEX notifications_user_id_post_id_key;
+ALTER TABLE notifications ADD CONSTRAINT notifications_user_id_post_id_key UNIQUE (user_id, post_id);
+ALTER TABLE notifications DROP COLUMN report_id;
+DROP TABLE post_reports;
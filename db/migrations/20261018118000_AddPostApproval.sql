@@ -0,0 +1,22 @@
+-- +goose Up
+-- Posts by new users can be held until a moderator approves them
+ALTER TABLE posts ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX posts_pending_idx ON posts (created_on) WHERE pending;
+
+-- Boards hold posts by users with fewer than approve_posts approved posts,
+-- or whose account is younger than approve_days. Zero turns a rule off.
+ALTER TABLE boards ADD COLUMN approve_posts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN approve_days INTEGER NOT NULL DEFAULT 0;
+
+INSERT INTO permissions (name, description) VALUES
+    ('post.approve', 'Approve or reject posts waiting for approval');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'post.approve');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='post.approve';
+ALTER TABLE boards DROP COLUMN approve_days;
+ALTER TABLE boards DROP COLUMN approve_posts;
+DROP INDEX posts_pending_idx;
+ALTER TABLE posts DROP COLUMN pending;
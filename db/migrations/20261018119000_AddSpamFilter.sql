@@ -0,0 +1,29 @@
+-- +goose Up
+-- How many posts marked as spam and not spam each feature turned up in
+CREATE TABLE spam_tokens (
+    token VARCHAR(64) PRIMARY KEY,
+    spam_count INTEGER NOT NULL DEFAULT 0,
+    ham_count INTEGER NOT NULL DEFAULT 0
+);
+
+-- Every post a moderator has marked as spam or not spam. The features it
+-- was trained on are kept so a changed verdict can be taken back exactly,
+-- and there's no foreign key so the model outlives purged posts.
+CREATE TABLE spam_verdicts (
+    post_id INTEGER PRIMARY KEY,
+    spam BOOLEAN NOT NULL,
+    held BOOLEAN NOT NULL DEFAULT FALSE,
+    features TEXT NOT NULL,
+    marked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    marked_on TIMESTAMP NOT NULL
+);
+
+-- The filter's score when the post was made, and whether it held the post
+ALTER TABLE posts ADD COLUMN spam_score DOUBLE PRECISION;
+ALTER TABLE posts ADD COLUMN spam_held BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN spam_held;
+ALTER TABLE posts DROP COLUMN spam_score;
+DROP TABLE spam_verdicts;
+DROP TABLE spam_tokens;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Every email gets its own unsubscribe token, and only their hashes are kept
+CREATE TABLE IF NOT EXISTS unsubscribe_tokens (
+    token       VARCHAR(64) PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_on  TIMESTAMP NOT NULL
+);
+
+-- Links in emails which have already been sent keep working
+INSERT INTO unsubscribe_tokens (token, user_id, created_on)
+    SELECT encode(sha256(convert_to(unsubscribe_token, 'UTF8')), 'hex'), id, NOW()
+    FROM users WHERE unsubscribe_token != '';
+
+ALTER TABLE users DROP COLUMN unsubscribe_token;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN unsubscribe_token VARCHAR(64) NOT NULL DEFAULT '';
+DROP TABLE unsubscribe_tokens;
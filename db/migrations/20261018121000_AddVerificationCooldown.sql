@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN verification_sent_on TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN verification_sent_on;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- What the spam filter scored the post on when it was published, separated
+-- by spaces, so moderators' verdicts teach it from the same features
+ALTER TABLE posts ADD COLUMN spam_features TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN spam_features;
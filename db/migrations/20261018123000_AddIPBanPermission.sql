@@ -0,0 +1,11 @@
+-- +goose Up
+-- Banning IP ranges catches everyone behind them, so it gets its own
+-- permission instead of coming with user.ban
+INSERT INTO permissions (name, description) VALUES
+    ('ip.ban', 'Ban IP addresses and ranges');
+
+INSERT INTO group_permissions (group_id, permission) VALUES
+    (2, 'ip.ban');
+
+-- +goose Down
+DELETE FROM permissions WHERE name='ip.ban';
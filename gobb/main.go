@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"go/build"
 	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/gorilla/mux"
@@ -20,9 +21,28 @@ func main() {
 	flag.StringVar(&config_path, "config", "gobb.conf", "Specifies the location of a config file")
 	run_migrations := flag.Bool("migrate", false, "Runs database migrations")
 	ign_migrations := flag.Bool("ignore-migrations", false, "Ignores an out of date database and runs the server anyways")
+	check_sanitizer := flag.Bool("check-sanitizer", false, "Checks the HTML sanitizer policy against a corpus of XSS payloads and exits")
 	flag.Parse()
 	config.GetConfig(config_path)
 
+	if *check_sanitizer {
+		failures, total, err := utils.CheckSanitizerCorpus(utils.GetSanitizerCorpusPath())
+		if err != nil {
+			fmt.Printf("[error] Could not read XSS corpus (%s)\n", err.Error())
+			os.Exit(1)
+		}
+
+		for _, failure := range failures {
+			fmt.Printf("[error] Unsafe output: %s\n", failure)
+		}
+
+		fmt.Printf("[notice] %d of %d payloads sanitized safely\n", total-len(failures), total)
+		if len(failures) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Do we need to run migrations?
 	latest_db_version, migrations, err := utils.GetMigrationInfo()
 	if len(migrations) != 0 && *run_migrations {
@@ -44,24 +64,77 @@ func main() {
 	r.StrictSlash(true)
 
 	r.HandleFunc("/", controllers.Index)
+	r.HandleFunc("/feed.{format:atom|rss}", controllers.SiteFeed)
 	r.HandleFunc("/register", controllers.Register)
 	r.HandleFunc("/login", controllers.Login)
 	r.HandleFunc("/logout", controllers.Logout)
 	r.HandleFunc("/admin", controllers.Admin)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}", controllers.AdminBoard)
 	r.HandleFunc("/admin/boards", controllers.AdminBoards)
 	r.HandleFunc("/admin/users/{id:[0-9]+}", controllers.AdminUser)
 	r.HandleFunc("/admin/users", controllers.AdminUsers)
+	r.HandleFunc("/admin/groups/{id:[0-9]+}", controllers.AdminGroup)
+	r.HandleFunc("/admin/groups", controllers.AdminGroups)
+	r.HandleFunc("/admin/bans", controllers.AdminBans)
+	r.HandleFunc("/admin/modlog", controllers.AdminModLog)
+	r.HandleFunc("/admin/webhooks/{id:[0-9]+}", controllers.AdminWebhook)
+	r.HandleFunc("/admin/webhooks", controllers.AdminWebhooks)
+	r.HandleFunc("/admin/message_reports", controllers.AdminMessageReports)
+	r.HandleFunc("/admin/spam", controllers.AdminSpam)
 	r.HandleFunc("/action/stick", controllers.ActionStickThread)
 	r.HandleFunc("/action/lock", controllers.ActionLockThread)
 	r.HandleFunc("/action/delete", controllers.ActionDeleteThread)
 	r.HandleFunc("/action/move", controllers.ActionMoveThread)
 	r.HandleFunc("/action/mark_read", controllers.ActionMarkAllRead)
 	r.HandleFunc("/action/edit", controllers.PostEditor)
+	r.HandleFunc("/action/watch", controllers.ActionWatchThread)
+	r.HandleFunc("/action/watch_board", controllers.ActionWatchBoard)
+	r.HandleFunc("/action/bookmark", controllers.ActionBookmark)
+	r.HandleFunc("/action/leave_conversation", controllers.ActionLeaveConversation)
+	r.HandleFunc("/action/block", controllers.ActionBlockUser)
+	r.HandleFunc("/action/report_message", controllers.ActionReportMessage)
+	r.HandleFunc("/action/report", controllers.ActionReportPost)
+	r.HandleFunc("/action/spam", controllers.ActionMarkSpam)
 	r.HandleFunc("/board/{id:[0-9]+}", controllers.Board)
+	r.HandleFunc("/board/{id:[0-9]+}/feed.{format:atom|rss}", controllers.BoardFeed)
 	r.HandleFunc("/board/{board_id:[0-9]+}/new", controllers.PostEditor)
 	r.HandleFunc("/board/{board_id:[0-9]+}/{post_id:[0-9]+}", controllers.Thread)
+	r.HandleFunc("/board/{board_id:[0-9]+}/{post_id:[0-9]+}/feed.{format:atom|rss}", controllers.ThreadFeed)
+	r.HandleFunc("/board/{board_id:[0-9]+}/{post_id:[0-9]+}/events", controllers.ThreadEvents)
 	r.HandleFunc("/user/{id:[0-9]+}", controllers.User)
 	r.HandleFunc("/user/{id:[0-9]+}/settings", controllers.UserSettings)
+	r.HandleFunc("/user/{id:[0-9]+}/settings/sessions", controllers.UserSessions)
+	r.HandleFunc("/user/{id:[0-9]+}/settings/tokens", controllers.UserAPITokens)
+	r.HandleFunc("/user/{id:[0-9]+}/ban", controllers.UserBan)
+	r.HandleFunc("/notifications", controllers.Notifications)
+	r.HandleFunc("/notifications/{id:[0-9]+}", controllers.Notification)
+	r.HandleFunc("/subscriptions", controllers.Subscriptions)
+	r.HandleFunc("/bookmarks", controllers.Bookmarks)
+	r.HandleFunc("/messages", controllers.Messages)
+	r.HandleFunc("/messages/sent", controllers.SentMessages)
+	r.HandleFunc("/messages/new", controllers.NewConversation)
+	r.HandleFunc("/messages/blocked", controllers.BlockedUsers)
+	r.HandleFunc("/messages/{id:[0-9]+}", controllers.Conversation)
+	r.HandleFunc("/verify_email/{token:[0-9a-f]+}", controllers.VerifyEmail)
+	r.HandleFunc("/unsubscribe/{token:[0-9a-f]+}", controllers.Unsubscribe)
+	r.HandleFunc("/trash", controllers.Trash)
+	r.HandleFunc("/reports", controllers.Reports)
+	r.HandleFunc("/pending", controllers.PendingPosts)
+	r.HandleFunc("/post/{id:[0-9]+}/history", controllers.PostHistory)
+	r.HandleFunc("/search", controllers.Search)
+
+	// JSON API
+	r.HandleFunc("/api/v1/me", controllers.APIMe)
+	r.HandleFunc("/api/v1/boards", controllers.APIBoards)
+	r.HandleFunc("/api/v1/boards/{id:[0-9]+}", controllers.APIBoard)
+	r.HandleFunc("/api/v1/boards/{id:[0-9]+}/threads", controllers.APIBoardThreads)
+	r.HandleFunc("/api/v1/threads/{id:[0-9]+}", controllers.APIThread)
+	r.HandleFunc("/api/v1/threads/{id:[0-9]+}/posts", controllers.APIThreadPosts)
+	r.HandleFunc("/api/v1/threads/{id:[0-9]+}/{action:stick|unstick|lock|unlock|move}", controllers.APIThreadModerate)
+	r.HandleFunc("/api/v1/posts/{id:[0-9]+}", controllers.APIPost)
+	r.HandleFunc("/api/v1/users/{id:[0-9]+}", controllers.APIUser)
+	r.HandleFunc("/api/v1/users/{id:[0-9]+}/posts", controllers.APIUserPosts)
+	r.PathPrefix("/api/").HandlerFunc(controllers.APINotFound)
 
 	// Handle static files
 	selected_template, _ := models.GetStringSetting("template")
@@ -83,6 +156,10 @@ func main() {
 
 	http.Handle("/", r)
 
+	models.StartTrashPurger()
+	models.StartWebhookWorker()
+	utils.StartMailWorker()
+
 	port, err := config.Config.GetString("gobb", "port")
 	if err != nil {
 		port = "8080"
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// What an API token may be used for
+const (
+	// Only GET requests
+	TokenScopeRead = "read"
+	// Anything the owner could do themselves
+	TokenScopeWrite = "write"
+	// Reading, plus starting threads and changing posts in one board
+	TokenScopeBoard = "board"
+)
+
+// Every token starts with this, so they're easy to spot in scripts and
+// config files
+const apiTokenPrefix = "gobb_"
+
+// Tokens can be made to last at most this many days, or forever
+const maxAPITokenDays = 365
+
+// An APIToken lets scripts use the API on a user's behalf without their
+// password. Like sessions, only a hash of the token is kept.
+type APIToken struct {
+	ID         int64         `db:"id"`
+	UserID     int64         `db:"user_id"`
+	Name       string        `db:"name"`
+	Token      string        `db:"token"`
+	Scope      string        `db:"scope"`
+	BoardID    sql.NullInt64 `db:"board_id"`
+	CreatedOn  time.Time     `db:"created_on"`
+	LastUsed   pq.NullTime   `db:"last_used"`
+	LastUsedIP string        `db:"last_used_ip"`
+	ExpiresOn  pq.NullTime   `db:"expires_on"`
+}
+
+// Creates and stores a new token for the user. The board is only used by
+// the board scope, and a duration of zero means it never expires. The
+// plaintext token is returned separately and is never stored.
+func NewAPIToken(user *User, name, scope string, board *Board, duration time.Duration) (*APIToken, string, error) {
+	token := &APIToken{
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(name),
+		Scope:     scope,
+		CreatedOn: time.Now(),
+	}
+
+	if scope == TokenScopeBoard && board != nil {
+		token.BoardID = sql.NullInt64{Int64: board.ID, Valid: true}
+	}
+
+	if duration > 0 {
+		token.ExpiresOn = pq.NullTime{Time: time.Now().Add(duration), Valid: true}
+	}
+
+	if err := token.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	plaintext := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	token.Token = hashSessionToken(plaintext)
+
+	db := GetDbSession()
+	if err := db.Insert(token); err != nil {
+		return nil, "", err
+	}
+
+	return token, plaintext, nil
+}
+
+// Reads how many days a new token should last, as picked on the settings
+// page. Zero means it never expires; anything other than that or 1 to 365
+// days is refused.
+func ParseAPITokenExpiry(raw string) (time.Duration, error) {
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 || days > maxAPITokenDays {
+		return 0, fmt.Errorf("Tokens can last from 1 to %d days, or never expire", maxAPITokenDays)
+	}
+
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+func (token *APIToken) Validate() error {
+	if len(token.Name) == 0 {
+		return errors.New("Give the token a name so you can tell it apart from others")
+	}
+
+	if len(token.Name) > 64 {
+		return errors.New("Token names must be at most 64 characters")
+	}
+
+	switch token.Scope {
+	case TokenScopeRead, TokenScopeWrite:
+	case TokenScopeBoard:
+		if !token.BoardID.Valid {
+			return errors.New("Pick the board the token may post in")
+		}
+	default:
+		return errors.New("Unknown token scope")
+	}
+
+	return nil
+}
+
+func GetAPIToken(ID int) (*APIToken, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&APIToken{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*APIToken), err
+}
+
+// Looks up an unexpired token from the plaintext a script sent us
+func GetAPITokenByToken(plaintext string) (*APIToken, error) {
+	if !strings.HasPrefix(plaintext, apiTokenPrefix) {
+		return nil, errors.New("Malformed API token")
+	}
+
+	db := GetDbSession()
+	token := &APIToken{}
+	err := db.SelectOne(token, "SELECT * FROM api_tokens WHERE token=$1 AND (expires_on IS NULL OR expires_on > $2)", hashSessionToken(plaintext), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Returns all of the user's tokens, including expired ones, newest first
+func (user *User) GetAPITokens() ([]*APIToken, error) {
+	db := GetDbSession()
+
+	var tokens []*APIToken
+	_, err := db.Select(&tokens, "SELECT * FROM api_tokens WHERE user_id=$1 ORDER BY created_on DESC", user.ID)
+
+	return tokens, err
+}
+
+// Records that the token was just used. Writes are skipped if it was
+// used within the last minute from the same address.
+func (token *APIToken) Touch(ip string) {
+	if token.LastUsed.Valid && time.Since(token.LastUsed.Time) < time.Minute && token.LastUsedIP == ip {
+		return
+	}
+
+	token.LastUsed = pq.NullTime{Time: time.Now(), Valid: true}
+	token.LastUsedIP = ip
+
+	db := GetDbSession()
+	db.Update(token)
+}
+
+// Deletes the token. It stops working immediately.
+func (token *APIToken) Revoke() error {
+	db := GetDbSession()
+	_, err := db.Delete(token)
+	return err
+}
+
+func (token *APIToken) IsExpired() bool {
+	return token.ExpiresOn.Valid && !token.ExpiresOn.Time.After(time.Now())
+}
+
+// Whether the token may be used to change things on the given board. Pass
+// a nil board for changes that aren't tied to one, such as moderation.
+func (token *APIToken) CanWrite(board *Board) bool {
+	switch token.Scope {
+	case TokenScopeWrite:
+		return true
+	case TokenScopeBoard:
+		return board != nil && token.BoardID.Valid && board.ID == token.BoardID.Int64
+	}
+
+	return false
+}
+
+func (token *APIToken) GetBoard() *Board {
+	if !token.BoardID.Valid {
+		return nil
+	}
+
+	board, _ := GetBoard(int(token.BoardID.Int64))
+	return board
+}
+
+// Describes the scope in a few words for the settings page
+func (token *APIToken) DescribeScope() string {
+	switch token.Scope {
+	case TokenScopeRead:
+		return "Read only"
+	case TokenScopeWrite:
+		return "Full access"
+	case TokenScopeBoard:
+		if board := token.GetBoard(); board != nil {
+			return "Post in " + board.Title
+		}
+		return "Post in a deleted board"
+	}
+
+	return token.Scope
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestParseAPITokenExpiry(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1", 24 * time.Hour, true},
+		{"365", 365 * 24 * time.Hour, true},
+		{"366", 0, false},
+		{"-1", 0, false},
+		{"", 0, false},
+		{"seven", 0, false},
+		// Would overflow a time.Duration if it got that far
+		{"9223372036854775807", 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := ParseAPITokenExpiry(test.raw)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseAPITokenExpiry(%q) = %v, %v; want %v, ok %v", test.raw, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestAPITokenIsExpired(t *testing.T) {
+	tests := []struct {
+		expires pq.NullTime
+		want    bool
+	}{
+		{pq.NullTime{}, false},
+		{pq.NullTime{Time: time.Now().Add(time.Hour), Valid: true}, false},
+		{pq.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}, true},
+	}
+
+	for _, test := range tests {
+		token := &APIToken{ExpiresOn: test.expires}
+		if got := token.IsExpired(); got != test.want {
+			t.Errorf("IsExpired() with expiry %v = %v, want %v", test.expires, got, test.want)
+		}
+	}
+}
+
+func TestExpiredAPITokenIsRefused(t *testing.T) {
+	db := requireTestDB(t)
+	user, _ := newTestUserAndBoard(t, db)
+
+	token, plaintext, err := NewAPIToken(user, "Test", TokenScopeRead, nil, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Revoke()
+
+	if found, err := GetAPITokenByToken(plaintext); err != nil || found.ID != token.ID {
+		t.Fatalf("Could not look up a fresh token: %v", err)
+	}
+
+	token.ExpiresOn = pq.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+	if _, err = db.Update(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, _ := GetAPITokenByToken(plaintext); found != nil {
+		t.Error("An expired token was accepted")
+	}
+}
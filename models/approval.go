@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Whether the board holds the user's new posts until a moderator approves
+// them. People who can approve posts never wait themselves.
+func (board *Board) NeedsApproval(user *User) bool {
+	if board.ApprovePosts <= 0 && board.ApproveDays <= 0 {
+		return false
+	}
+
+	if Can(user, PermPostApprove, board) {
+		return false
+	}
+
+	if board.ApproveDays > 0 && time.Since(user.CreatedOn) < time.Duration(board.ApproveDays)*24*time.Hour {
+		return true
+	}
+
+	if board.ApprovePosts > 0 {
+		db := GetDbSession()
+		count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE author_id=$1 AND NOT pending AND deleted_at IS NULL", user.ID)
+		if err != nil {
+			fmt.Printf("[error] Could not count approved posts (%s)\n", err.Error())
+			return true
+		}
+
+		return count < int64(board.ApprovePosts)
+	}
+
+	return false
+}
+
+// Whether the user may see a post, as far as approval goes. Pending posts
+// are only visible to their author and the people who can approve them.
+func (post *Post) IsVisibleTo(user *User, board *Board) bool {
+	if !post.Pending {
+		return true
+	}
+
+	if user == nil {
+		return false
+	}
+
+	return post.AuthorID == user.ID || Can(user, PermPostApprove, board)
+}
+
+// Returns the pending replies in the thread the user may see, oldest first
+func (thread *Post) GetPendingReplies(user *User, board *Board) []*Post {
+	if user == nil {
+		return nil
+	}
+
+	db := GetDbSession()
+
+	var posts []*Post
+	var err error
+	if Can(user, PermPostApprove, board) {
+		_, err = db.Select(&posts, "SELECT * FROM posts WHERE parent_id=$1 AND pending AND deleted_at IS NULL ORDER BY created_on ASC", thread.ID)
+	} else {
+		_, err = db.Select(&posts, "SELECT * FROM posts WHERE parent_id=$1 AND pending AND deleted_at IS NULL AND author_id=$2 ORDER BY created_on ASC", thread.ID, user.ID)
+	}
+	if err != nil {
+		fmt.Printf("[error] Could not get pending replies (%s)\n", err.Error())
+	}
+
+	return posts
+}
+
+// Returns the posts waiting for approval in boards where the user can
+// approve them, oldest first
+func GetPendingPosts(user *User) ([]*Post, error) {
+	db := GetDbSession()
+
+	var posts []*Post
+	_, err := db.Select(&posts, "SELECT * FROM posts WHERE pending AND deleted_at IS NULL ORDER BY created_on ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	boards := map[int64]*Board{}
+	var allowed []*Post
+	for _, post := range posts {
+		board, ok := boards[post.BoardID]
+		if !ok {
+			board, _ = GetBoard(int(post.BoardID))
+			boards[post.BoardID] = board
+		}
+
+		if board != nil && Can(user, PermPostApprove, board) {
+			allowed = append(allowed, post)
+		}
+	}
+
+	return allowed, nil
+}
+
+// Whether the user should see the approval queue at all
+func (user *User) CanApprovePosts() bool {
+	if Can(user, PermPostApprove, nil) {
+		return true
+	}
+
+	db := GetDbSession()
+	count, _ := db.SelectInt("SELECT COUNT(*) FROM board_moderators WHERE user_id=$1", user.ID)
+	return count > 0
+}
+
+// How many posts are waiting in the user's approval queue
+func (user *User) CountPendingPosts() int {
+	posts, err := GetPendingPosts(user)
+	if err != nil {
+		fmt.Printf("[error] Could not count pending posts (%s)\n", err.Error())
+		return 0
+	}
+
+	return len(posts)
+}
+
+// Makes a pending post visible to everyone. It's treated as new from the
+// moment it's approved, so it bumps its thread and shows up as unread for
+// people who read the thread while it was waiting.
+func (post *Post) Approve() error {
+	now := time.Now()
+	post.Pending = false
+	post.CreatedOn = now
+	post.LatestReply = now
+
+	db := GetDbSession()
+	if _, err := db.Update(post); err != nil {
+		return err
+	}
+
+	return post.announce()
+}
+
+// Turns down a pending post. It goes to the trash with the reason, and its
+// author is told why.
+func (post *Post) Reject(moderator *User, reason string) error {
+	if err := post.SoftDelete(moderator, reason); err != nil {
+		return err
+	}
+
+	// A post can be restored from the trash and rejected again
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM notifications WHERE user_id=$1 AND post_id=$2 AND type=$3", post.AuthorID, post.ID, NotificationRejected)
+	if err != nil {
+		return err
+	}
+
+	return db.Insert(&Notification{
+		UserID:    post.AuthorID,
+		ActorID:   moderator.ID,
+		PostID:    post.ID,
+		Type:      NotificationRejected,
+		CreatedOn: time.Now(),
+	})
+}
@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestIsVisibleTo(t *testing.T) {
+	setGroupPermissions(t, map[int64][]string{
+		DefaultGroupID:       {PermPostReply},
+		AdministratorGroupID: {PermPostReply, PermPostApprove},
+	})
+
+	author := &User{ID: 1, GroupID: DefaultGroupID}
+	member := &User{ID: 2, GroupID: DefaultGroupID}
+	admin := &User{ID: 3, GroupID: AdministratorGroupID}
+	moderator := &User{ID: 4, GroupID: DefaultGroupID}
+
+	board := newTestBoard(
+		&BoardAccess{GroupID: DefaultGroupID, CanRead: true, CanReply: true},
+		&BoardAccess{GroupID: AdministratorGroupID, CanRead: true, CanReply: true},
+	)
+	board.GuestRead = true
+	board.moderatorCache[moderator.ID] = true
+
+	published := &Post{ID: 1, AuthorID: author.ID}
+	pending := &Post{ID: 2, AuthorID: author.ID, Pending: true}
+
+	tests := []struct {
+		post *Post
+		user *User
+		want bool
+	}{
+		{published, nil, true},
+		{published, member, true},
+		{pending, nil, false},
+		{pending, member, false},
+		{pending, author, true},
+		{pending, admin, true},
+		{pending, moderator, true},
+	}
+
+	for _, test := range tests {
+		if got := test.post.IsVisibleTo(test.user, board); got != test.want {
+			t.Errorf("Post %d IsVisibleTo(%v) = %v, want %v", test.post.ID, test.user, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A Ban stops an account, an IP range, or both from logging in, registering
+// and posting. Bans are never deleted so that they show up in a user's
+// history; lifting one early just records when and by whom.
+type Ban struct {
+	ID        int64          `db:"id"`
+	UserID    sql.NullInt64  `db:"user_id"`
+	IPRange   sql.NullString `db:"ip_range"`
+	Reason    string         `db:"reason"`
+	CreatedOn time.Time      `db:"created_on"`
+	ExpiresOn pq.NullTime    `db:"expires_on"`
+	BannedBy  int64          `db:"banned_by"`
+	LiftedOn  pq.NullTime    `db:"lifted_on"`
+	LiftedBy  sql.NullInt64  `db:"lifted_by"`
+}
+
+// Initializes a ban. Either user or ipRange may be empty, but not both.
+// A zero duration makes the ban permanent.
+func NewBan(user *User, ipRange string, moderator *User, reason string, duration time.Duration) *Ban {
+	ban := &Ban{
+		Reason:    reason,
+		CreatedOn: time.Now(),
+		BannedBy:  moderator.ID,
+	}
+
+	if user != nil {
+		ban.UserID = sql.NullInt64{Int64: user.ID, Valid: true}
+	}
+
+	if ipRange != "" {
+		ban.IPRange = sql.NullString{String: ipRange, Valid: true}
+	}
+
+	if duration > 0 {
+		ban.ExpiresOn = pq.NullTime{Time: time.Now().Add(duration), Valid: true}
+	}
+
+	return ban
+}
+
+// Accepts a single address or a CIDR range and returns it in CIDR form
+func NormalizeIPRange(in string) (string, error) {
+	in = strings.TrimSpace(in)
+	if !strings.Contains(in, "/") {
+		ip := net.ParseIP(in)
+		if ip == nil {
+			return "", errors.New("Invalid IP address")
+		}
+
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+
+	_, network, err := net.ParseCIDR(in)
+	if err != nil {
+		return "", errors.New("Invalid CIDR range")
+	}
+
+	return network.String(), nil
+}
+
+func (ban *Ban) Validate() error {
+	if !ban.UserID.Valid && !ban.IPRange.Valid {
+		return errors.New("A ban needs a user or an IP range")
+	}
+
+	if len(strings.TrimSpace(ban.Reason)) == 0 {
+		return errors.New("Please give a reason for the ban")
+	}
+
+	if len(ban.Reason) > 255 {
+		return errors.New("Reason must be at most 255 characters")
+	}
+
+	return nil
+}
+
+// Checks the moderator may ban the user at all. Nobody can ban themselves
+// or an administrator, and moderators can't ban anyone holding a
+// permission they don't have themselves.
+func CheckCanBan(moderator *User, user *User) error {
+	if !Can(moderator, PermUserBan, nil) {
+		return errors.New("You don't have permission to ban users")
+	}
+
+	if user.ID == moderator.ID {
+		return errors.New("You can't ban yourself")
+	}
+
+	if user.GroupID == AdministratorGroupID {
+		return errors.New("Administrators can't be banned")
+	}
+
+	own := getGroupPermissionSet(moderator.GroupID)
+	for permission := range getGroupPermissionSet(user.GroupID) {
+		if !own[permission] {
+			return errors.New("You can't ban someone with permissions you don't have")
+		}
+	}
+
+	return nil
+}
+
+// The widest ranges that can be banned. Anything wider risks locking out
+// whole providers, or the whole forum.
+const (
+	minIPv4BanPrefix = 16
+	minIPv6BanPrefix = 48
+)
+
+// Checks the moderator may ban the range. Range bans catch everyone behind
+// them, so they need their own permission, can't be too wide, and can't
+// cover the address the moderator is using.
+func CheckCanBanIPRange(moderator *User, ipRange string, ownIP string) error {
+	if !Can(moderator, PermIPBan, nil) {
+		return errors.New("You don't have permission to ban IP addresses")
+	}
+
+	_, network, err := net.ParseCIDR(ipRange)
+	if err != nil {
+		return errors.New("Invalid CIDR range")
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits == 32 && ones < minIPv4BanPrefix {
+		return fmt.Errorf("IPv4 ranges can be at most /%d", minIPv4BanPrefix)
+	}
+	if bits == 128 && ones < minIPv6BanPrefix {
+		return fmt.Errorf("IPv6 ranges can be at most /%d", minIPv6BanPrefix)
+	}
+
+	if ip := net.ParseIP(ownIP); ip != nil && network.Contains(ip) {
+		return errors.New("You can't ban a range that includes your own address")
+	}
+
+	return nil
+}
+
+// Checks the moderator may lift the ban. Whoever couldn't have placed a
+// ban can't lift it either.
+func (ban *Ban) CheckCanLift(moderator *User) error {
+	if ban.IPRange.Valid && !Can(moderator, PermIPBan, nil) {
+		return errors.New("You don't have permission to lift IP bans")
+	}
+
+	if user := ban.GetUser(); user != nil {
+		return CheckCanBan(moderator, user)
+	}
+
+	return nil
+}
+
+// Users who can't be banned aren't caught by IP bans either, so a range
+// ban can never lock the administrators out
+func IsExemptFromIPBans(user *User) bool {
+	return user != nil && user.GroupID == AdministratorGroupID
+}
+
+func GetBan(ID int) (*Ban, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Ban{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Ban), err
+}
+
+// Bans which haven't been lifted and haven't run out yet. The current
+// time is always the first query argument.
+const activeBanSQL = "lifted_on IS NULL AND (expires_on IS NULL OR expires_on > $1)"
+
+// Returns the longest running ban on the user's account, or nil
+func GetActiveUserBan(user *User) *Ban {
+	if user == nil {
+		return nil
+	}
+
+	db := GetDbSession()
+	ban := &Ban{}
+	err := db.SelectOne(ban, "SELECT * FROM bans WHERE "+activeBanSQL+" AND user_id=$2 ORDER BY expires_on DESC NULLS FIRST LIMIT 1", time.Now(), user.ID)
+	if err != nil || ban.ID == 0 {
+		return nil
+	}
+
+	return ban
+}
+
+// Returns the longest running ban covering the address, or nil
+func GetActiveIPBan(ip string) *Ban {
+	if net.ParseIP(ip) == nil {
+		return nil
+	}
+
+	db := GetDbSession()
+	ban := &Ban{}
+	err := db.SelectOne(ban, "SELECT * FROM bans WHERE "+activeBanSQL+" AND ip_range >>= $2::inet ORDER BY expires_on DESC NULLS FIRST LIMIT 1", time.Now(), ip)
+	if err != nil || ban.ID == 0 {
+		return nil
+	}
+
+	return ban
+}
+
+// Returns every ban still in effect, newest first
+func GetActiveBans() ([]*Ban, error) {
+	db := GetDbSession()
+
+	var bans []*Ban
+	_, err := db.Select(&bans, "SELECT * FROM bans WHERE "+activeBanSQL+" ORDER BY created_on DESC", time.Now())
+
+	return bans, err
+}
+
+// Returns every ban the user has ever received, newest first
+func (user *User) GetBans() ([]*Ban, error) {
+	db := GetDbSession()
+
+	var bans []*Ban
+	_, err := db.Select(&bans, "SELECT * FROM bans WHERE user_id=$1 ORDER BY created_on DESC", user.ID)
+
+	return bans, err
+}
+
+func (ban *Ban) IsPermanent() bool {
+	return !ban.ExpiresOn.Valid
+}
+
+func (ban *Ban) IsActive() bool {
+	if ban.LiftedOn.Valid {
+		return false
+	}
+
+	return ban.IsPermanent() || ban.ExpiresOn.Time.After(time.Now())
+}
+
+func (ban *Ban) GetUser() *User {
+	if !ban.UserID.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(ban.UserID.Int64))
+	return user
+}
+
+func (ban *Ban) GetBannedBy() *User {
+	user, _ := GetUser(int(ban.BannedBy))
+	return user
+}
+
+func (ban *Ban) GetLiftedBy() *User {
+	if !ban.LiftedBy.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(ban.LiftedBy.Int64))
+	return user
+}
+
+// Ends the ban early
+func (ban *Ban) Lift(moderator *User) error {
+	if err := ban.CheckCanLift(moderator); err != nil {
+		return err
+	}
+
+	ban.LiftedOn = pq.NullTime{Time: time.Now(), Valid: true}
+	ban.LiftedBy = sql.NullInt64{Int64: moderator.ID, Valid: true}
+
+	db := GetDbSession()
+	_, err := db.Update(ban)
+	return err
+}
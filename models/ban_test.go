@@ -0,0 +1,109 @@
+package models
+
+import "testing"
+
+func TestCheckCanBan(t *testing.T) {
+	const moderatorGroupID int64 = 3
+	const helperGroupID int64 = 4
+	setGroupPermissions(t, map[int64][]string{
+		DefaultGroupID:       {PermPostCreate, PermPostReply},
+		AdministratorGroupID: {PermPostCreate, PermPostReply, PermUserBan, PermPostApprove, PermWebhookManage, PermSettingsEdit},
+		moderatorGroupID:     {PermPostCreate, PermPostReply, PermUserBan, PermPostApprove},
+		helperGroupID:        {PermPostCreate, PermPostReply, PermUserBan, PermWebhookManage},
+	})
+
+	member := &User{ID: 1, GroupID: DefaultGroupID}
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+	moderator := &User{ID: 3, GroupID: moderatorGroupID}
+	otherModerator := &User{ID: 4, GroupID: moderatorGroupID}
+	helper := &User{ID: 5, GroupID: helperGroupID}
+
+	tests := []struct {
+		moderator *User
+		user      *User
+		allowed   bool
+	}{
+		{moderator, member, true},
+		{moderator, otherModerator, true},
+		{admin, moderator, true},
+		{member, member, false},
+		{member, admin, false},
+		{moderator, moderator, false},
+		{moderator, admin, false},
+		{admin, admin, false},
+		// Both can ban, but each has something the other lacks
+		{moderator, helper, false},
+		{helper, moderator, false},
+	}
+
+	for _, test := range tests {
+		err := CheckCanBan(test.moderator, test.user)
+		if (err == nil) != test.allowed {
+			t.Errorf("CheckCanBan(%d, %d) = %v, want allowed %v", test.moderator.ID, test.user.ID, err, test.allowed)
+		}
+	}
+}
+
+func TestCheckCanBanIPRange(t *testing.T) {
+	const moderatorGroupID int64 = 3
+	setGroupPermissions(t, map[int64][]string{
+		AdministratorGroupID: {PermUserBan, PermIPBan},
+		moderatorGroupID:     {PermUserBan},
+	})
+
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+	moderator := &User{ID: 3, GroupID: moderatorGroupID}
+
+	tests := []struct {
+		moderator *User
+		ipRange   string
+		allowed   bool
+	}{
+		{admin, "203.0.113.7/32", true},
+		{admin, "203.0.0.0/16", true},
+		{admin, "2001:db8:1::/48", true},
+		{admin, "203.0.0.0/15", false},
+		{admin, "0.0.0.0/0", false},
+		{admin, "2001:db8::/32", false},
+		{admin, "::/0", false},
+		// Covers the address the admin is using
+		{admin, "198.51.100.0/24", false},
+		{admin, "not a range", false},
+		{moderator, "203.0.113.7/32", false},
+	}
+
+	for _, test := range tests {
+		err := CheckCanBanIPRange(test.moderator, test.ipRange, "198.51.100.20")
+		if (err == nil) != test.allowed {
+			t.Errorf("CheckCanBanIPRange(%d, %q) = %v, want allowed %v", test.moderator.ID, test.ipRange, err, test.allowed)
+		}
+	}
+}
+
+func TestCheckCanLift(t *testing.T) {
+	const moderatorGroupID int64 = 3
+	setGroupPermissions(t, map[int64][]string{
+		AdministratorGroupID: {PermUserBan, PermIPBan},
+		moderatorGroupID:     {PermUserBan},
+	})
+
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+	moderator := &User{ID: 3, GroupID: moderatorGroupID}
+
+	ban := NewBan(nil, "203.0.113.0/24", admin, "Spam", 0)
+	if err := ban.CheckCanLift(moderator); err == nil {
+		t.Error("A moderator without ip.ban could lift an IP ban")
+	}
+	if err := ban.CheckCanLift(admin); err != nil {
+		t.Errorf("An admin couldn't lift an IP ban: %v", err)
+	}
+}
+
+func TestIsExemptFromIPBans(t *testing.T) {
+	if !IsExemptFromIPBans(&User{GroupID: AdministratorGroupID}) {
+		t.Error("Administrators should never be caught by IP bans")
+	}
+	if IsExemptFromIPBans(&User{GroupID: DefaultGroupID}) || IsExemptFromIPBans(nil) {
+		t.Error("Only administrators should be exempt from IP bans")
+	}
+}
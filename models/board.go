@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
 	"math"
 	"time"
@@ -10,10 +11,29 @@ import (
 )
 
 type Board struct {
-	ID          int64  `db:"id"`
-	Title       string `db:"title"`
-	Description string `db:"description"`
-	Order       int    `db:"ordering"`
+	ID           int64  `db:"id"`
+	Title        string `db:"title"`
+	Description  string `db:"description"`
+	Order        int    `db:"ordering"`
+	GuestRead    bool   `db:"guest_read"`
+	DefaultRead  bool   `db:"default_read"`
+	DefaultPost  bool   `db:"default_post"`
+	DefaultReply bool   `db:"default_reply"`
+
+	// Posts by users with fewer approved posts than ApprovePosts, or whose
+	// accounts are younger than ApproveDays days, wait for a moderator
+	ApprovePosts int `db:"approve_posts"`
+	ApproveDays  int `db:"approve_days"`
+
+	// Set when the board is in the trash
+	DeletedAt    pq.NullTime   `db:"deleted_at"`
+	DeletedBy    sql.NullInt64 `db:"deleted_by"`
+	DeleteReason string        `db:"delete_reason"`
+
+	// Access rules looked up so far, keyed by group ID
+	accessCache map[int64]*BoardAccess `db:"-"`
+	// Whether users are moderators of this board, keyed by user ID
+	moderatorCache map[int64]bool `db:"-"`
 }
 
 type BoardLatest struct {
@@ -22,12 +42,21 @@ type BoardLatest struct {
 }
 
 type JoinBoardView struct {
-	Board       *Board      `db:"-"`
-	ID          int64       `db:"id"`
-	Title       string      `db:"title"`
-	Description string      `db:"description"`
-	Order       int         `db:"ordering"`
-	ViewedOn    pq.NullTime `db:"viewed_on"`
+	Board        *Board        `db:"-"`
+	ID           int64         `db:"id"`
+	Title        string        `db:"title"`
+	Description  string        `db:"description"`
+	Order        int           `db:"ordering"`
+	GuestRead    bool          `db:"guest_read"`
+	DefaultRead  bool          `db:"default_read"`
+	DefaultPost  bool          `db:"default_post"`
+	DefaultReply bool          `db:"default_reply"`
+	ApprovePosts int           `db:"approve_posts"`
+	ApproveDays  int           `db:"approve_days"`
+	DeletedAt    pq.NullTime   `db:"deleted_at"`
+	DeletedBy    sql.NullInt64 `db:"deleted_by"`
+	DeleteReason string        `db:"delete_reason"`
+	ViewedOn     pq.NullTime   `db:"viewed_on"`
 }
 
 type JoinThreadView struct {
@@ -46,18 +75,13 @@ type JoinThreadView struct {
 
 func NewBoard(title, desc string, order int) *Board {
 	return &Board{
-		Title:       title,
-		Description: desc,
-		Order:       order,
-	}
-}
-
-func UpdateBoard(title, desc string, order int, ID int64) *Board {
-	return &Board{
-		Title:       title,
-		Description: desc,
-		Order:       order,
-		ID:          ID,
+		Title:        title,
+		Description:  desc,
+		Order:        order,
+		GuestRead:    true,
+		DefaultRead:  true,
+		DefaultPost:  true,
+		DefaultReply: true,
 	}
 }
 
@@ -75,7 +99,17 @@ func GetBoards() ([]*Board, error) {
 	db := GetDbSession()
 
 	var boards []*Board
-	_, err := db.Select(&boards, "SELECT * FROM boards ORDER BY ordering ASC")
+	_, err := db.Select(&boards, "SELECT * FROM boards WHERE deleted_at IS NULL ORDER BY ordering ASC")
+
+	return boards, err
+}
+
+// Returns only the boards the given user is allowed to read
+func GetReadableBoards(user *User) ([]*Board, error) {
+	db := GetDbSession()
+
+	var boards []*Board
+	_, err := db.Select(&boards, "SELECT * FROM boards WHERE "+boardReadableSQL(user)+" ORDER BY ordering ASC")
 
 	return boards, err
 }
@@ -95,8 +129,9 @@ func GetBoardsUnread(user *User) ([]*JoinBoardView, error) {
             views.time AS viewed_on
         FROM boards
         LEFT OUTER JOIN views ON
-            views.post_id=(SELECT id FROM posts WHERE board_id=boards.id AND parent_id IS NULL ORDER BY latest_reply DESC LIMIT 1) AND
+            views.post_id=(SELECT id FROM posts WHERE board_id=boards.id AND parent_id IS NULL AND deleted_at IS NULL AND NOT pending ORDER BY latest_reply DESC LIMIT 1) AND
             views.user_id=$1
+        WHERE `+boardReadableSQL(user)+`
         ORDER BY
             ordering ASC
     `, userID)
@@ -107,25 +142,54 @@ func GetBoardsUnread(user *User) ([]*JoinBoardView, error) {
 		}
 
 		boards[i].Board = &Board{
-			ID: boards[i].ID,
+			ID:           boards[i].ID,
+			Title:        boards[i].Title,
+			Description:  boards[i].Description,
+			Order:        boards[i].Order,
+			GuestRead:    boards[i].GuestRead,
+			DefaultRead:  boards[i].DefaultRead,
+			DefaultPost:  boards[i].DefaultPost,
+			DefaultReply: boards[i].DefaultReply,
+			ApprovePosts: boards[i].ApprovePosts,
+			ApproveDays:  boards[i].ApproveDays,
 		}
 	}
 
 	return boards, err
 }
 
+// Whether the board has seen activity since the user last looked at it
+func (join *JoinBoardView) IsUnread(user *User) bool {
+	latest := join.Board.GetLatestPost()
+
+	if user != nil && !user.LastUnreadAll.Time.Before(latest.Op.LatestReply) {
+		return false
+	}
+
+	return !join.ViewedOn.Valid || join.ViewedOn.Time.Before(latest.Op.LatestReply)
+}
+
+// Whether the thread has new replies since the user last read it
+func (join *JoinThreadView) IsUnread(user *User) bool {
+	if user != nil && !user.LastUnreadAll.Time.Before(join.LatestReply) {
+		return false
+	}
+
+	return !join.ViewedOn.Valid || join.ViewedOn.Time.Before(join.LatestReply)
+}
+
 func (board *Board) GetLatestPost() BoardLatest {
 	db := GetDbSession()
 	op := &Post{}
 	latest := &Post{}
 
-	err := db.SelectOne(op, "SELECT * FROM posts WHERE board_id=$1 AND parent_id IS NULL ORDER BY latest_reply DESC LIMIT 1", board.ID)
+	err := db.SelectOne(op, "SELECT * FROM posts WHERE board_id=$1 AND parent_id IS NULL AND deleted_at IS NULL AND NOT pending ORDER BY latest_reply DESC LIMIT 1", board.ID)
 
 	if err != nil {
 		fmt.Printf("[error] Could not get latest post in board %d (%s)\n", board.ID, err.Error())
 	}
 
-	err = db.SelectOne(latest, "SELECT * FROM posts WHERE board_id=$1 AND parent_id=$2 ORDER BY created_on DESC LIMIT 1", board.ID, op.ID)
+	err = db.SelectOne(latest, "SELECT * FROM posts WHERE board_id=$1 AND parent_id=$2 AND deleted_at IS NULL AND NOT pending ORDER BY created_on DESC LIMIT 1", board.ID, op.ID)
 
 	if latest.Author == nil {
 		latest = nil
@@ -165,7 +229,9 @@ func (board *Board) GetThreads(page int, user *User) ([]*JoinThreadView, error)
             views.user_id=$4
         WHERE
             board_id=$1 AND
-            parent_id IS NULL
+            parent_id IS NULL AND
+            deleted_at IS NULL AND
+            NOT pending
         ORDER BY
             sticky DESC,
             latest_reply DESC
@@ -190,7 +256,7 @@ func (board *Board) GetThreads(page int, user *User) ([]*JoinThreadView, error)
 
 func (board *Board) GetPagesInBoard() int {
 	db := GetDbSession()
-	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE board_id=$1 AND parent_id IS NULL", board.ID)
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE board_id=$1 AND parent_id IS NULL AND deleted_at IS NULL AND NOT pending", board.ID)
 
 	threadsPerPage, err := config.Config.GetInt64("gobb", "threads_per_page")
 
@@ -200,10 +266,3 @@ func (board *Board) GetPagesInBoard() int {
 
 	return int(math.Floor(float64(count) / float64(threadsPerPage)))
 }
-
-// Delete a board and all of the posts it contains
-func (board *Board) Delete() {
-	db := GetDbSession()
-	db.Exec("DELETE FROM posts WHERE board_id=$1", board.ID)
-	db.Delete(board)
-}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+)
+
+// Overrides a board's default access rules for members of one group
+type BoardAccess struct {
+	BoardID  int64 `db:"board_id"`
+	GroupID  int64 `db:"group_id"`
+	CanRead  bool  `db:"can_read"`
+	CanPost  bool  `db:"can_post"`
+	CanReply bool  `db:"can_reply"`
+}
+
+// Works out what the user may do on the board. Visitors who aren't logged
+// in can at most read, and only if the board allows guests. Groups without
+// a row in board_access get the board's defaults. Nobody can do anything
+// on a board in the trash.
+func (board *Board) GetAccess(user *User) *BoardAccess {
+	if board.IsDeleted() {
+		return &BoardAccess{BoardID: board.ID, GroupID: -1}
+	}
+
+	if user == nil {
+		return &BoardAccess{
+			BoardID: board.ID,
+			GroupID: -1,
+			CanRead: board.GuestRead,
+		}
+	}
+
+	// Templates check access for every post on a page, so remember the
+	// answer for as long as this board struct is around.
+	if access, ok := board.accessCache[user.GroupID]; ok {
+		return access
+	}
+
+	access := &BoardAccess{
+		BoardID:  board.ID,
+		GroupID:  user.GroupID,
+		CanRead:  board.DefaultRead,
+		CanPost:  board.DefaultPost,
+		CanReply: board.DefaultReply,
+	}
+
+	db := GetDbSession()
+	obj, _ := db.Get(&BoardAccess{}, board.ID, user.GroupID)
+	if obj != nil {
+		access = obj.(*BoardAccess)
+	}
+
+	if board.accessCache == nil {
+		board.accessCache = map[int64]*BoardAccess{}
+	}
+	board.accessCache[user.GroupID] = access
+
+	return access
+}
+
+// Returns the per-group overrides for the board, keyed by group ID
+func (board *Board) GetAccessOverrides() (map[int64]*BoardAccess, error) {
+	db := GetDbSession()
+
+	var rows []*BoardAccess
+	_, err := db.Select(&rows, "SELECT * FROM board_access WHERE board_id=$1", board.ID)
+
+	overrides := map[int64]*BoardAccess{}
+	for _, row := range rows {
+		overrides[row.GroupID] = row
+	}
+
+	return overrides, err
+}
+
+// Replaces every per-group override for the board
+func (board *Board) SetAccessOverrides(overrides []*BoardAccess) error {
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM board_access WHERE board_id=$1", board.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, override := range overrides {
+		override.BoardID = board.ID
+		err = tx.Insert(override)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Returns a SQL condition which is true for rows of the boards table the
+// user is allowed to read. Only integers are interpolated.
+// Boards in the trash can't be read by anyone.
+func boardReadableSQL(user *User) string {
+	if user == nil {
+		return "(boards.deleted_at IS NULL AND boards.guest_read)"
+	}
+
+	return fmt.Sprintf(`(boards.deleted_at IS NULL AND COALESCE(
+            (SELECT can_read FROM board_access WHERE board_access.board_id=boards.id AND board_access.group_id=%d),
+            boards.default_read))`, user.GroupID)
+}
+
+// Same as boardReadableSQL, but for rows of the posts table
+func postReadableSQL(user *User) string {
+	return "posts.board_id IN (SELECT boards.id FROM boards WHERE " + boardReadableSQL(user) + ")"
+}
@@ -0,0 +1,74 @@
+package models
+
+// Makes a user a moderator of a single board, on top of whatever their
+// group allows them to do everywhere else.
+type BoardModerator struct {
+	BoardID int64 `db:"board_id"`
+	UserID  int64 `db:"user_id"`
+}
+
+// Permissions which board moderators get on the boards they moderate
+var boardModeratorPermissions = map[string]bool{
+	PermPostEdit:     true,
+	PermPostDelete:   true,
+	PermThreadStick:  true,
+	PermThreadLock:   true,
+	PermThreadMove:   true,
+	PermReportHandle: true,
+	PermPostApprove:  true,
+}
+
+// Reports whether the user was made a moderator of this board
+func (board *Board) IsModerator(user *User) bool {
+	if user == nil {
+		return false
+	}
+
+	if board.moderatorCache == nil {
+		board.moderatorCache = map[int64]bool{}
+	} else if is, ok := board.moderatorCache[user.ID]; ok {
+		return is
+	}
+
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM board_moderators WHERE board_id=$1 AND user_id=$2", board.ID, user.ID)
+	is := err == nil && count > 0
+	board.moderatorCache[user.ID] = is
+
+	return is
+}
+
+func (board *Board) GetModerators() ([]*User, error) {
+	db := GetDbSession()
+
+	var users []*User
+	_, err := db.Select(&users, `
+        SELECT users.*
+        FROM users
+        INNER JOIN board_moderators ON board_moderators.user_id=users.id
+        WHERE board_moderators.board_id=$1
+        ORDER BY users.username ASC
+    `, board.ID)
+
+	return users, err
+}
+
+func (board *Board) AddModerator(user *User) error {
+	if board.IsModerator(user) {
+		return nil
+	}
+
+	db := GetDbSession()
+	err := db.Insert(&BoardModerator{BoardID: board.ID, UserID: user.ID})
+	board.moderatorCache = nil
+
+	return err
+}
+
+func (board *Board) RemoveModerator(user *User) error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM board_moderators WHERE board_id=$1 AND user_id=$2", board.ID, user.ID)
+	board.moderatorCache = nil
+
+	return err
+}
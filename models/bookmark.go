@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// A post the user wants to be able to find again, with an optional note
+// to remind them why
+type Bookmark struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	PostID    int64     `db:"post_id"`
+	Note      string    `db:"note"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+func NewBookmark(user *User, post *Post, note string) *Bookmark {
+	return &Bookmark{
+		UserID:    user.ID,
+		PostID:    post.ID,
+		Note:      strings.TrimSpace(note),
+		CreatedOn: time.Now(),
+	}
+}
+
+func (bookmark *Bookmark) Validate() error {
+	if len(bookmark.Note) > 255 {
+		return errors.New("Notes must be at most 255 characters")
+	}
+
+	return nil
+}
+
+func GetBookmark(ID int) (*Bookmark, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Bookmark{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Bookmark), err
+}
+
+// Returns the user's bookmark of the post, or nil if they haven't
+// bookmarked it
+func (user *User) GetBookmark(post *Post) *Bookmark {
+	db := GetDbSession()
+	bookmark := &Bookmark{}
+	err := db.SelectOne(bookmark, "SELECT * FROM bookmarks WHERE user_id=$1 AND post_id=$2", user.ID, post.ID)
+	if err != nil {
+		return nil
+	}
+
+	return bookmark
+}
+
+// Returns the user's bookmarks of posts they can still see, newest first
+func (user *User) GetBookmarks() ([]*Bookmark, error) {
+	db := GetDbSession()
+
+	var bookmarks []*Bookmark
+	_, err := db.Select(&bookmarks, `
+        SELECT bookmarks.*
+        FROM bookmarks
+        INNER JOIN posts ON posts.id=bookmarks.post_id
+        WHERE
+            bookmarks.user_id=$1 AND
+            posts.deleted_at IS NULL AND
+            NOT posts.pending AND
+            `+postReadableSQL(user)+`
+        ORDER BY bookmarks.created_on DESC
+    `, user.ID)
+
+	return bookmarks, err
+}
+
+func (bookmark *Bookmark) Delete() error {
+	db := GetDbSession()
+	_, err := db.Delete(bookmark)
+	return err
+}
+
+func (bookmark *Bookmark) GetPost() *Post {
+	post, _ := GetPost(int(bookmark.PostID))
+	return post
+}
+
+// Returns the thread the bookmarked post is in
+func (bookmark *Bookmark) GetThread() *Post {
+	post := bookmark.GetPost()
+	if post == nil || !post.ParentID.Valid {
+		return post
+	}
+
+	thread, _ := GetPost(int(post.ParentID.Int64))
+	return thread
+}
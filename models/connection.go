@@ -62,6 +62,32 @@ func GetDbSession() *gorp.DbMap {
 	dbMap.AddTableWithName(Post{}, "posts").SetKeys(true, "ID")
 	dbMap.AddTableWithName(View{}, "views").SetKeys(false, "ID")
 	dbMap.AddTableWithName(Setting{}, "settings").SetKeys(true, "Key")
+	dbMap.AddTableWithName(Session{}, "sessions").SetKeys(true, "ID")
+	dbMap.AddTableWithName(Group{}, "groups").SetKeys(true, "ID")
+	dbMap.AddTableWithName(Permission{}, "permissions").SetKeys(false, "Name")
+	dbMap.AddTableWithName(GroupPermission{}, "group_permissions").SetKeys(false, "GroupID", "Permission")
+	dbMap.AddTableWithName(BoardAccess{}, "board_access").SetKeys(false, "BoardID", "GroupID")
+	dbMap.AddTableWithName(BoardModerator{}, "board_moderators").SetKeys(false, "BoardID", "UserID")
+	dbMap.AddTableWithName(Ban{}, "bans").SetKeys(true, "ID")
+	dbMap.AddTableWithName(ModLogEntry{}, "mod_log").SetKeys(true, "ID")
+	dbMap.AddTableWithName(PostRevision{}, "post_revisions").SetKeys(true, "ID")
+	dbMap.AddTableWithName(APIToken{}, "api_tokens").SetKeys(true, "ID")
+	dbMap.AddTableWithName(Webhook{}, "webhooks").SetKeys(true, "ID")
+	dbMap.AddTableWithName(WebhookDelivery{}, "webhook_deliveries").SetKeys(true, "ID")
+	dbMap.AddTableWithName(Notification{}, "notifications").SetKeys(true, "ID")
+	dbMap.AddTableWithName(ThreadSubscription{}, "thread_subscriptions").SetKeys(false, "ThreadID", "UserID")
+	dbMap.AddTableWithName(BoardSubscription{}, "board_subscriptions").SetKeys(false, "BoardID", "UserID")
+	dbMap.AddTableWithName(Bookmark{}, "bookmarks").SetKeys(true, "ID")
+	dbMap.AddTableWithName(Email{}, "emails").SetKeys(true, "ID")
+	dbMap.AddTableWithName(UnsubscribeToken{}, "unsubscribe_tokens").SetKeys(false, "Token")
+	dbMap.AddTableWithName(Conversation{}, "conversations").SetKeys(true, "ID")
+	dbMap.AddTableWithName(ConversationParticipant{}, "conversation_participants").SetKeys(false, "ConversationID", "UserID")
+	dbMap.AddTableWithName(Message{}, "messages").SetKeys(true, "ID")
+	dbMap.AddTableWithName(UserBlock{}, "user_blocks").SetKeys(false, "UserID", "BlockedID")
+	dbMap.AddTableWithName(MessageReport{}, "message_reports").SetKeys(true, "ID")
+	dbMap.AddTableWithName(PostReport{}, "post_reports").SetKeys(true, "ID")
+	dbMap.AddTableWithName(SpamToken{}, "spam_tokens").SetKeys(false, "Token")
+	dbMap.AddTableWithName(SpamVerdict{}, "spam_verdicts").SetKeys(false, "PostID")
 
 	return dbMap
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/coopernurse/gorp"
+	"github.com/stevenleeg/gobb/config"
+)
+
+// Returns a connection to the test database, or skips the test if there
+// isn't one. Point GOBB_TEST_CONFIG at a config file for a migrated,
+// throwaway database to run these tests.
+func requireTestDB(t *testing.T) *gorp.DbMap {
+	path := os.Getenv("GOBB_TEST_CONFIG")
+	if path == "" {
+		t.Skip("GOBB_TEST_CONFIG isn't set")
+	}
+
+	config.GetConfig(path)
+	db := GetDbSession()
+	if db == nil {
+		t.Fatal("Could not connect to the test database")
+	}
+
+	return db
+}
+
+// Adds a user and a board for a test to post in. Both are removed again,
+// along with anything posted, when the test finishes.
+func newTestUserAndBoard(t *testing.T, db *gorp.DbMap) (*User, *Board) {
+	user, err := NewUser(fmt.Sprintf("test%d", time.Now().UnixNano()), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+
+	board := NewBoard("Test board", "", 0)
+	if err = db.Insert(board); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		board.Purge()
+		db.Delete(user)
+	})
+
+	return user, board
+}
+
+// Inserts a post, as a reply if a thread is given
+func newTestPost(t *testing.T, db *gorp.DbMap, author *User, board *Board, thread *Post) *Post {
+	post := NewPost(author, board, "Title", "Content")
+	if thread != nil {
+		post.ParentID = sql.NullInt64{Int64: thread.ID, Valid: true}
+	}
+	if err := db.Insert(post); err != nil {
+		t.Fatal(err)
+	}
+
+	return post
+}
@@ -0,0 +1,360 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// How often a user is sent a digest of their watched boards
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+const (
+	// An email is given up on after this many tries
+	emailMaxAttempts = 8
+	// Wait before the first retry, doubled after every failure after that
+	emailRetryDelay = time.Minute
+	// How many due emails the sender picks up at once
+	EmailBatchSize = 20
+	// Notifications older than this are never emailed, so verifying an
+	// address doesn't bring a flood of old news
+	notificationEmailWindow = time.Hour
+	// Verification emails go to an address, and to a user, at most once
+	// in this long, so they can't be used to flood someone's inbox
+	verificationEmailCooldown = 10 * time.Minute
+)
+
+// An email waiting to be sent, or a record of one that was. The body is
+// plain text.
+type Email struct {
+	ID             int64         `db:"id"`
+	UserID         sql.NullInt64 `db:"user_id"`
+	ToAddress      string        `db:"to_address"`
+	Subject        string        `db:"subject"`
+	Body           string        `db:"body"`
+	UnsubscribeURL string        `db:"unsubscribe_url"`
+	Status         string        `db:"status"`
+	Attempts       int           `db:"attempts"`
+	NextAttempt    time.Time     `db:"next_attempt"`
+	LastAttempt    pq.NullTime   `db:"last_attempt"`
+	Error          string        `db:"error"`
+	CreatedOn      time.Time     `db:"created_on"`
+}
+
+// Lets whoever holds an email unsubscribe its recipient. See
+// NewUnsubscribeToken.
+type UnsubscribeToken struct {
+	Token     string    `db:"token"`
+	UserID    int64     `db:"user_id"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// Queues an email to the user's address. It's sent by the mail worker.
+func QueueEmail(user *User, subject, body, unsubscribeURL string) error {
+	now := time.Now()
+
+	db := GetDbSession()
+	return db.Insert(&Email{
+		UserID:         sql.NullInt64{Int64: user.ID, Valid: true},
+		ToAddress:      user.Email,
+		Subject:        subject,
+		Body:           body,
+		UnsubscribeURL: unsubscribeURL,
+		Status:         EmailPending,
+		NextAttempt:    now,
+		CreatedOn:      now,
+	})
+}
+
+// Returns the emails that are due to be sent, oldest first
+func GetDueEmails() ([]*Email, error) {
+	db := GetDbSession()
+
+	var emails []*Email
+	_, err := db.Select(&emails, "SELECT * FROM emails WHERE status=$1 AND next_attempt <= $2 ORDER BY next_attempt ASC LIMIT $3", EmailPending, time.Now(), EmailBatchSize)
+
+	return emails, err
+}
+
+// Records the outcome of an attempt at sending the email, scheduling
+// another attempt if it failed and there are tries left
+func (email *Email) RecordAttempt(sendErr error) error {
+	email.Attempts++
+	email.LastAttempt = pq.NullTime{Time: time.Now(), Valid: true}
+	email.Error = ""
+
+	if sendErr == nil {
+		email.Status = EmailSent
+	} else {
+		email.Error = sendErr.Error()
+		if email.Attempts >= emailMaxAttempts {
+			email.Status = EmailFailed
+		} else {
+			// 1m, 2m, 4m... between tries
+			backoff := emailRetryDelay * time.Duration(1<<uint(email.Attempts-1))
+			email.NextAttempt = time.Now().Add(backoff)
+		}
+	}
+
+	db := GetDbSession()
+	_, err := db.Update(email)
+	return err
+}
+
+func randomEmailToken() string {
+	raw := make([]byte, 24)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// Changes the user's email address. New addresses need verifying before
+// anything other than the verification email is sent to them, so the
+// plaintext verification token is returned for that email. An empty
+// address removes it.
+func (user *User) SetEmail(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == user.Email {
+		return "", nil
+	}
+
+	user.EmailVerified = false
+	user.EmailToken = ""
+	user.Email = address
+	if address == "" {
+		return "", nil
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Address != address || len(address) > 254 {
+		return "", errors.New("That doesn't look like an email address")
+	}
+
+	if verificationSentRecently(address) {
+		return "", errors.New("A verification email was sent to that address a few minutes ago. Please wait a little before trying again.")
+	}
+
+	return user.NewEmailToken(), nil
+}
+
+// Starts a new verification of the user's current address, returning the
+// plaintext token to send them
+func (user *User) NewEmailToken() string {
+	token := randomEmailToken()
+	user.EmailToken = hashSessionToken(token)
+	user.VerificationSentOn = pq.NullTime{Time: time.Now(), Valid: true}
+	return token
+}
+
+// Whether the user may be sent another verification email yet
+func (user *User) CanResendVerification() error {
+	sentRecently := user.VerificationSentOn.Valid && time.Since(user.VerificationSentOn.Time) < verificationEmailCooldown
+	if sentRecently || verificationSentRecently(user.Email) {
+		return errors.New("A verification email was sent a few minutes ago. Please wait a little before asking for another.")
+	}
+
+	return nil
+}
+
+// Whether a verification email has gone to the address lately, for any
+// account
+func verificationSentRecently(address string) bool {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM users WHERE LOWER(email)=LOWER($1) AND verification_sent_on > $2", address, time.Now().Add(-verificationEmailCooldown))
+	if err != nil {
+		fmt.Printf("[error] Could not check for recent verification emails (%s)\n", err.Error())
+		return true
+	}
+
+	return count > 0
+}
+
+// Marks the address belonging to the verification token as verified and
+// returns its user
+func VerifyEmail(token string) (*User, error) {
+	if token == "" {
+		return nil, errors.New("Invalid verification link")
+	}
+
+	db := GetDbSession()
+	user := &User{}
+	err := db.SelectOne(user, "SELECT * FROM users WHERE email_token=$1", hashSessionToken(token))
+	if err != nil {
+		return nil, errors.New("Invalid verification link")
+	}
+
+	user.EmailVerified = true
+	user.EmailToken = ""
+	_, err = db.Update(user)
+
+	return user, err
+}
+
+// Whether anything may be emailed to the user
+func (user *User) CanReceiveEmail() bool {
+	return user.Email != "" && user.EmailVerified
+}
+
+// Makes a token for the unsubscribe links in an email to the user. Every
+// email gets its own, and like sessions only a hash of it is stored.
+func (user *User) NewUnsubscribeToken() (string, error) {
+	token := randomEmailToken()
+
+	db := GetDbSession()
+	err := db.Insert(&UnsubscribeToken{
+		Token:     hashSessionToken(token),
+		UserID:    user.ID,
+		CreatedOn: time.Now(),
+	})
+
+	return token, err
+}
+
+func GetUserByUnsubscribeToken(token string) (*User, error) {
+	if token == "" {
+		return nil, errors.New("Invalid unsubscribe link")
+	}
+
+	db := GetDbSession()
+	user := &User{}
+	err := db.SelectOne(user, "SELECT users.* FROM users INNER JOIN unsubscribe_tokens ON unsubscribe_tokens.user_id=users.id WHERE unsubscribe_tokens.token=$1", hashSessionToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Returns the users who have notifications waiting to be emailed
+func GetUsersWithUnemailedNotifications() ([]*User, error) {
+	db := GetDbSession()
+
+	var users []*User
+	_, err := db.Select(&users, `
+        SELECT * FROM users WHERE
+            email != '' AND
+            email_verified AND
+            email_notifications AND
+            id IN (SELECT user_id FROM notifications WHERE NOT emailed AND NOT read AND created_on > $1)
+    `, time.Now().Add(-notificationEmailWindow))
+
+	return users, err
+}
+
+// Returns the user's unread notifications which haven't been emailed yet,
+// oldest first. They're left as they are until MarkNotificationsEmailed
+// is called, so nothing is lost if the email can't be queued.
+func (user *User) GetUnemailedNotifications() ([]*Notification, error) {
+	db := GetDbSession()
+
+	var notifications []*Notification
+	_, err := db.Select(&notifications, "SELECT * FROM notifications WHERE user_id=$1 AND NOT emailed AND NOT read AND created_on > $2 AND "+notificationVisibleSQL(user)+" ORDER BY created_on ASC, id ASC", user.ID, time.Now().Add(-notificationEmailWindow))
+
+	return notifications, err
+}
+
+// Records that the notifications have been emailed. Only the ones given
+// are marked, so any created since they were fetched go in the next email.
+func MarkNotificationsEmailed(notifications []*Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(notifications))
+	args := make([]interface{}, len(notifications))
+	for i, notification := range notifications {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = notification.ID
+	}
+
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE notifications SET emailed=TRUE WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	return err
+}
+
+// How long the user waits between digests
+func (user *User) GetDigestInterval() time.Duration {
+	switch user.EmailDigest {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// Returns the users whose next digest is due
+func GetUsersDueDigest() ([]*User, error) {
+	db := GetDbSession()
+	now := time.Now()
+
+	var users []*User
+	_, err := db.Select(&users, `
+        SELECT * FROM users WHERE
+            email != '' AND
+            email_verified AND
+            ((email_digest=$1 AND (last_digest IS NULL OR last_digest <= $3)) OR
+             (email_digest=$2 AND (last_digest IS NULL OR last_digest <= $4)))
+    `, DigestDaily, DigestWeekly, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour))
+
+	return users, err
+}
+
+// Returns the threads in the user's watched boards with activity they
+// haven't seen since their last digest, most recently active first
+func (user *User) GetDigestThreads() ([]*Post, error) {
+	since := time.Now().Add(-user.GetDigestInterval())
+	if user.LastDigest.Valid && user.LastDigest.Time.After(since) {
+		since = user.LastDigest.Time
+	}
+
+	db := GetDbSession()
+
+	var threads []*Post
+	_, err := db.Select(&threads, `
+        SELECT posts.* FROM posts
+        INNER JOIN board_subscriptions ON
+            board_subscriptions.board_id=posts.board_id AND
+            board_subscriptions.user_id=$1
+        LEFT OUTER JOIN views ON
+            views.post_id=posts.id AND
+            views.user_id=$1
+        WHERE
+            posts.parent_id IS NULL AND
+            posts.deleted_at IS NULL AND
+            NOT posts.pending AND
+            posts.latest_reply > GREATEST(views.time, board_subscriptions.created_on, $2::timestamp, $3::timestamp) AND
+            `+postReadableSQL(user)+`
+        ORDER BY posts.latest_reply DESC
+        LIMIT 50
+    `, user.ID, since, user.LastUnreadAll)
+
+	return threads, err
+}
+
+// Records that the user was just sent a digest, or would have been had
+// there been anything in it
+func (user *User) MarkDigestSent() error {
+	user.LastDigest = pq.NullTime{Time: time.Now(), Valid: true}
+
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE users SET last_digest=$1 WHERE id=$2", user.LastDigest, user.ID)
+	return err
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+)
+
+// Groups seeded by the migrations. New users land in the default group and
+// the first user to register is put in the administrator group. Neither
+// can be deleted.
+const (
+	DefaultGroupID       int64 = 0
+	AdministratorGroupID int64 = 2
+)
+
+type Group struct {
+	ID          int64  `db:"id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+}
+
+func NewGroup(name, description string) *Group {
+	return &Group{
+		Name:        name,
+		Description: description,
+	}
+}
+
+func GetGroup(ID int) (*Group, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Group{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Group), err
+}
+
+func GetGroups() ([]*Group, error) {
+	db := GetDbSession()
+
+	var groups []*Group
+	_, err := db.Select(&groups, "SELECT * FROM groups ORDER BY id ASC")
+
+	return groups, err
+}
+
+func (group *Group) Validate() error {
+	if len(group.Name) < 3 || len(group.Name) > 40 {
+		return errors.New("Group name must be between 3 and 40 characters")
+	}
+
+	if len(group.Description) > 140 {
+		return errors.New("Group description must be at most 140 characters")
+	}
+
+	return nil
+}
+
+func (group *Group) IsBuiltin() bool {
+	return group.ID == DefaultGroupID || group.ID == AdministratorGroupID
+}
+
+func (group *Group) Has(permission string) bool {
+	return getGroupPermissionSet(group.ID)[permission]
+}
+
+func (group *Group) GetMemberCount() int64 {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM users WHERE group_id=$1", group.ID)
+	if err != nil {
+		return 0
+	}
+
+	return count
+}
+
+// Replaces the group's permissions with the given list
+func (group *Group) SetPermissions(permissions []string) error {
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM group_permissions WHERE group_id=$1", group.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, permission := range permissions {
+		_, err = tx.Exec("INSERT INTO group_permissions (group_id, permission) VALUES ($1, $2)", group.ID, permission)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	clearPermissionCache()
+	return err
+}
+
+// Deletes the group, moving its members back to the default group
+func (group *Group) Delete() error {
+	if group.IsBuiltin() {
+		return errors.New("Built in groups can't be deleted")
+	}
+
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE users SET group_id=$1 WHERE group_id=$2", DefaultGroupID, group.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Delete(group)
+	clearPermissionCache()
+	return err
+}
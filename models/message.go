@@ -0,0 +1,540 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Not counting whoever starts the conversation
+	maxConversationRecipients = 10
+	conversationsPerPage      = 30
+)
+
+// What happened to a report about a private message
+const (
+	MessageReportOpen      = "open"
+	MessageReportDismissed = "dismissed"
+	MessageReportRemoved   = "removed"
+)
+
+// A private conversation between two or more users
+type Conversation struct {
+	ID            int64         `db:"id"`
+	Subject       string        `db:"subject"`
+	CreatedBy     sql.NullInt64 `db:"created_by"`
+	CreatedOn     time.Time     `db:"created_on"`
+	LatestMessage time.Time     `db:"latest_message"`
+}
+
+// Someone taking part in a conversation. LastRead is when they last looked
+// at it, which is what the other participants' read receipts come from.
+type ConversationParticipant struct {
+	ConversationID int64       `db:"conversation_id"`
+	UserID         int64       `db:"user_id"`
+	JoinedOn       time.Time   `db:"joined_on"`
+	LastRead       pq.NullTime `db:"last_read"`
+	LeftOn         pq.NullTime `db:"left_on"`
+}
+
+type Message struct {
+	ID             int64         `db:"id"`
+	ConversationID int64         `db:"conversation_id"`
+	AuthorID       int64         `db:"author_id"`
+	Content        string        `db:"content"`
+	CreatedOn      time.Time     `db:"created_on"`
+	RemovedBy      sql.NullInt64 `db:"removed_by"`
+	RemovedOn      pq.NullTime   `db:"removed_on"`
+}
+
+// A user who doesn't want to hear from another. Blocked users can't start
+// conversations with the blocker, and the blocker doesn't see anything
+// they write in conversations they're both already in.
+type UserBlock struct {
+	UserID    int64     `db:"user_id"`
+	BlockedID int64     `db:"blocked_id"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// A participant flagging a message as abusive. Moderators only ever see
+// reported messages, never the rest of the conversation.
+type MessageReport struct {
+	ID         int64         `db:"id"`
+	MessageID  int64         `db:"message_id"`
+	ReporterID int64         `db:"reporter_id"`
+	Reason     string        `db:"reason"`
+	Status     string        `db:"status"`
+	HandledBy  sql.NullInt64 `db:"handled_by"`
+	HandledOn  pq.NullTime   `db:"handled_on"`
+	CreatedOn  time.Time     `db:"created_on"`
+}
+
+// A conversation in someone's inbox or outbox. Latest is the newest
+// message that put it there.
+type ConversationSummary struct {
+	Conversation *Conversation `db:"-"`
+	ID           int64         `db:"id"`
+	Subject      string        `db:"subject"`
+	Latest       time.Time     `db:"latest"`
+	Unread       int64         `db:"unread"`
+}
+
+// A SQL condition which is true for rows of the messages table the user
+// should see. Only integers are interpolated.
+func messageVisibleSQL(user *User) string {
+	return fmt.Sprintf("(messages.removed_on IS NULL AND messages.author_id NOT IN (SELECT blocked_id FROM user_blocks WHERE user_blocks.user_id=%d))", user.ID)
+}
+
+// Parses a comma separated list of usernames into users, making sure each
+// of them can be sent a message by the author
+func GetMessageRecipients(author *User, usernames string) ([]*User, error) {
+	var recipients []*User
+	seen := map[int64]bool{author.ID: true}
+
+	for _, username := range strings.Split(usernames, ",") {
+		username = strings.TrimSpace(username)
+		if username == "" {
+			continue
+		}
+
+		user, _ := GetUserByUsername(username)
+		if user == nil {
+			return nil, fmt.Errorf("There's nobody called %s", username)
+		}
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+
+		if user.IsBlocking(author) {
+			return nil, fmt.Errorf("%s isn't accepting messages from you", user.Username)
+		}
+
+		recipients = append(recipients, user)
+	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New("Who is this message to?")
+	}
+
+	if len(recipients) > maxConversationRecipients {
+		return nil, fmt.Errorf("A conversation can have at most %d recipients", maxConversationRecipients)
+	}
+
+	return recipients, nil
+}
+
+func validateMessage(content string) error {
+	if len(strings.TrimSpace(content)) == 0 {
+		return errors.New("Your message is empty")
+	}
+
+	if len(content) > maxContentLength {
+		return fmt.Errorf("Messages must be at most %d characters", maxContentLength)
+	}
+
+	return nil
+}
+
+// Starts a conversation between the author and the recipients with the
+// author's first message
+func NewConversation(author *User, recipients []*User, subject, content string) (*Conversation, error) {
+	subject = strings.TrimSpace(subject)
+	if len(subject) == 0 {
+		return nil, errors.New("Your conversation needs a subject")
+	}
+	if len(subject) > 255 {
+		return nil, errors.New("Subjects must be at most 255 characters")
+	}
+	if err := validateMessage(content); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	conversation := &Conversation{
+		Subject:       subject,
+		CreatedBy:     nullID(author.ID),
+		CreatedOn:     now,
+		LatestMessage: now,
+	}
+
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Insert(conversation)
+	for i := -1; err == nil && i < len(recipients); i++ {
+		participant := &ConversationParticipant{
+			ConversationID: conversation.ID,
+			JoinedOn:       now,
+		}
+
+		if i == -1 {
+			participant.UserID = author.ID
+			participant.LastRead = pq.NullTime{Time: now, Valid: true}
+		} else {
+			participant.UserID = recipients[i].ID
+		}
+
+		err = tx.Insert(participant)
+	}
+	if err == nil {
+		err = tx.Insert(&Message{
+			ConversationID: conversation.ID,
+			AuthorID:       author.ID,
+			Content:        content,
+			CreatedOn:      now,
+		})
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return conversation, tx.Commit()
+}
+
+func GetConversation(ID int) (*Conversation, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Conversation{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Conversation), err
+}
+
+// Returns the user's place in the conversation, or nil if they aren't in
+// it or have left
+func (conversation *Conversation) GetParticipant(user *User) *ConversationParticipant {
+	if user == nil {
+		return nil
+	}
+
+	db := GetDbSession()
+	participant := &ConversationParticipant{}
+	err := db.SelectOne(participant, "SELECT * FROM conversation_participants WHERE conversation_id=$1 AND user_id=$2 AND left_on IS NULL", conversation.ID, user.ID)
+	if err != nil {
+		return nil
+	}
+
+	return participant
+}
+
+// Returns everyone who has been in the conversation, in the order they
+// joined
+func (conversation *Conversation) GetParticipants() []*ConversationParticipant {
+	db := GetDbSession()
+
+	var participants []*ConversationParticipant
+	_, err := db.Select(&participants, "SELECT * FROM conversation_participants WHERE conversation_id=$1 ORDER BY joined_on ASC, user_id ASC", conversation.ID)
+	if err != nil {
+		fmt.Printf("[error] Could not get participants (%s)\n", err.Error())
+	}
+
+	return participants
+}
+
+// Returns the messages in the conversation the user should see, oldest
+// first. Messages removed by a moderator are included so the conversation
+// still makes sense, but the template doesn't show what they said.
+func (conversation *Conversation) GetMessages(user *User) ([]*Message, error) {
+	db := GetDbSession()
+
+	var messages []*Message
+	_, err := db.Select(&messages, fmt.Sprintf(`
+        SELECT * FROM messages WHERE
+            conversation_id=$1 AND
+            author_id NOT IN (SELECT blocked_id FROM user_blocks WHERE user_blocks.user_id=%d)
+        ORDER BY created_on ASC, id ASC
+    `, user.ID), conversation.ID)
+
+	return messages, err
+}
+
+// Adds the author's reply to the conversation
+func (conversation *Conversation) Reply(author *User, content string) (*Message, error) {
+	if err := validateMessage(content); err != nil {
+		return nil, err
+	}
+
+	message := &Message{
+		ConversationID: conversation.ID,
+		AuthorID:       author.ID,
+		Content:        content,
+		CreatedOn:      time.Now(),
+	}
+
+	db := GetDbSession()
+	if err := db.Insert(message); err != nil {
+		return nil, err
+	}
+
+	conversation.LatestMessage = message.CreatedOn
+	if _, err := db.Update(conversation); err != nil {
+		return nil, err
+	}
+
+	// Writing a reply means they've read everything before it
+	_, err := db.Exec("UPDATE conversation_participants SET last_read=$1 WHERE conversation_id=$2 AND user_id=$3", message.CreatedOn, conversation.ID, author.ID)
+	return message, err
+}
+
+func (participant *ConversationParticipant) GetUser() *User {
+	user, _ := GetUser(int(participant.UserID))
+	return user
+}
+
+// Whether the participant has seen the conversation since the given time
+func (participant *ConversationParticipant) HasReadSince(since time.Time) bool {
+	return participant.LastRead.Valid && !participant.LastRead.Time.Before(since)
+}
+
+func (participant *ConversationParticipant) MarkRead() error {
+	participant.LastRead = pq.NullTime{Time: time.Now(), Valid: true}
+
+	db := GetDbSession()
+	_, err := db.Update(participant)
+	return err
+}
+
+// Takes the participant out of the conversation. It disappears from their
+// inbox and they stop getting its messages.
+func (participant *ConversationParticipant) Leave() error {
+	participant.LeftOn = pq.NullTime{Time: time.Now(), Valid: true}
+
+	db := GetDbSession()
+	_, err := db.Update(participant)
+	return err
+}
+
+// Returns a page of the conversations other people have written to the
+// user in, most recent first, along with the number of pages
+func (user *User) GetInbox(page int) ([]*ConversationSummary, int, error) {
+	return user.getConversations(page, "messages.author_id!=$1 AND "+messageVisibleSQL(user))
+}
+
+// Returns a page of the conversations the user has written in, ordered by
+// their latest message
+func (user *User) GetOutbox(page int) ([]*ConversationSummary, int, error) {
+	return user.getConversations(page, "messages.author_id=$1")
+}
+
+// Lists conversations the user is still in which have messages matching
+// the given condition on the messages table
+func (user *User) getConversations(page int, messageCondition string) ([]*ConversationSummary, int, error) {
+	db := GetDbSession()
+
+	from := `
+        FROM conversations
+        INNER JOIN conversation_participants ON
+            conversation_participants.conversation_id=conversations.id AND
+            conversation_participants.user_id=$1 AND
+            conversation_participants.left_on IS NULL
+        INNER JOIN messages ON
+            messages.conversation_id=conversations.id AND
+            ` + messageCondition
+
+	count, err := db.SelectInt("SELECT COUNT(DISTINCT conversations.id) "+from, user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var conversations []*ConversationSummary
+	_, err = db.Select(&conversations, `
+        SELECT
+            conversations.id,
+            conversations.subject,
+            MAX(messages.created_on) AS latest,
+            (SELECT COUNT(*) FROM messages WHERE
+                messages.conversation_id=conversations.id AND
+                messages.author_id!=$1 AND
+                messages.created_on > COALESCE(conversation_participants.last_read, '-infinity') AND
+                `+messageVisibleSQL(user)+`
+            ) AS unread
+        `+from+`
+        GROUP BY conversations.id, conversation_participants.last_read
+        ORDER BY latest DESC
+        LIMIT $2 OFFSET $3
+    `, user.ID, conversationsPerPage, page*conversationsPerPage)
+
+	for i := range conversations {
+		conversations[i].Conversation = &Conversation{
+			ID:      conversations[i].ID,
+			Subject: conversations[i].Subject,
+		}
+	}
+
+	pages := int(math.Ceil(float64(count) / float64(conversationsPerPage)))
+	return conversations, pages, err
+}
+
+// Counts the conversations with messages the user hasn't read yet
+func (user *User) CountUnreadConversations() int64 {
+	db := GetDbSession()
+	count, err := db.SelectInt(`
+        SELECT COUNT(DISTINCT messages.conversation_id)
+        FROM messages
+        INNER JOIN conversation_participants ON
+            conversation_participants.conversation_id=messages.conversation_id AND
+            conversation_participants.user_id=$1 AND
+            conversation_participants.left_on IS NULL
+        WHERE
+            messages.author_id!=$1 AND
+            messages.created_on > COALESCE(conversation_participants.last_read, '-infinity') AND
+            `+messageVisibleSQL(user), user.ID)
+	if err != nil {
+		fmt.Printf("[error] Could not count unread conversations (%s)\n", err.Error())
+		return 0
+	}
+
+	return count
+}
+
+func GetMessage(ID int) (*Message, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Message{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Message), err
+}
+
+func (message *Message) GetAuthor() *User {
+	user, _ := GetUser(int(message.AuthorID))
+	return user
+}
+
+func (message *Message) GetConversation() *Conversation {
+	conversation, _ := GetConversation(int(message.ConversationID))
+	return conversation
+}
+
+func (message *Message) IsRemoved() bool {
+	return message.RemovedOn.Valid
+}
+
+// Hides the message from everyone in its conversation
+func (message *Message) Remove(moderator *User) error {
+	message.RemovedBy = nullID(moderator.ID)
+	message.RemovedOn = pq.NullTime{Time: time.Now(), Valid: true}
+
+	db := GetDbSession()
+	_, err := db.Update(message)
+	return err
+}
+
+func (user *User) IsBlocking(other *User) bool {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM user_blocks WHERE user_id=$1 AND blocked_id=$2", user.ID, other.ID)
+	return err == nil && count > 0
+}
+
+func (user *User) Block(other *User) error {
+	if user.ID == other.ID {
+		return errors.New("You can't block yourself")
+	}
+	if user.IsBlocking(other) {
+		return nil
+	}
+
+	db := GetDbSession()
+	return db.Insert(&UserBlock{
+		UserID:    user.ID,
+		BlockedID: other.ID,
+		CreatedOn: time.Now(),
+	})
+}
+
+func (user *User) Unblock(other *User) error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM user_blocks WHERE user_id=$1 AND blocked_id=$2", user.ID, other.ID)
+	return err
+}
+
+// Returns the users this user has blocked, alphabetically
+func (user *User) GetBlockedUsers() ([]*User, error) {
+	db := GetDbSession()
+
+	var users []*User
+	_, err := db.Select(&users, "SELECT users.* FROM users INNER JOIN user_blocks ON user_blocks.blocked_id=users.id WHERE user_blocks.user_id=$1 ORDER BY users.username ASC", user.ID)
+
+	return users, err
+}
+
+// Reports a message to the moderators. Reporting the same message twice
+// just updates the reason.
+func ReportMessage(reporter *User, message *Message, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 1024 {
+		return errors.New("Reasons must be at most 1024 characters")
+	}
+	if message.AuthorID == reporter.ID {
+		return errors.New("You can't report your own message")
+	}
+
+	db := GetDbSession()
+	report := &MessageReport{}
+	err := db.SelectOne(report, "SELECT * FROM message_reports WHERE message_id=$1 AND reporter_id=$2", message.ID, reporter.ID)
+	if err == nil {
+		report.Reason = reason
+		report.Status = MessageReportOpen
+		_, err = db.Update(report)
+		return err
+	}
+
+	return db.Insert(&MessageReport{
+		MessageID:  message.ID,
+		ReporterID: reporter.ID,
+		Reason:     reason,
+		Status:     MessageReportOpen,
+		CreatedOn:  time.Now(),
+	})
+}
+
+func GetMessageReport(ID int) (*MessageReport, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&MessageReport{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*MessageReport), err
+}
+
+// Returns the reports waiting for a moderator, oldest first
+func GetOpenMessageReports() ([]*MessageReport, error) {
+	db := GetDbSession()
+
+	var reports []*MessageReport
+	_, err := db.Select(&reports, "SELECT * FROM message_reports WHERE status=$1 ORDER BY created_on ASC", MessageReportOpen)
+
+	return reports, err
+}
+
+// Closes every open report about the message with the given outcome
+func (message *Message) ResolveReports(moderator *User, status string) error {
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE message_reports SET status=$1, handled_by=$2, handled_on=$3 WHERE message_id=$4 AND status=$5", status, moderator.ID, time.Now(), message.ID, MessageReportOpen)
+	return err
+}
+
+func (report *MessageReport) GetMessage() *Message {
+	message, _ := GetMessage(int(report.MessageID))
+	return message
+}
+
+func (report *MessageReport) GetReporter() *User {
+	user, _ := GetUser(int(report.ReporterID))
+	return user
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMessage(t *testing.T) {
+	tests := []struct {
+		content string
+		ok      bool
+	}{
+		{"", false},
+		{"  \n ", false},
+		{"Hello", true},
+		{strings.Repeat("a", maxContentLength), true},
+		{strings.Repeat("a", maxContentLength+1), false},
+	}
+
+	for _, test := range tests {
+		if err := validateMessage(test.content); (err == nil) != test.ok {
+			t.Errorf("validateMessage() of %d bytes = %v, want ok %v", len(test.content), err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Everything moderators and admins can do that ends up in the mod log
+const (
+	ModActionThreadStick     = "thread.stick"
+	ModActionThreadUnstick   = "thread.unstick"
+	ModActionThreadLock      = "thread.lock"
+	ModActionThreadUnlock    = "thread.unlock"
+	ModActionThreadMove      = "thread.move"
+	ModActionPostEdit        = "post.edit"
+	ModActionPostDelete      = "post.delete"
+	ModActionPostRestore     = "post.restore"
+	ModActionPostPurge       = "post.purge"
+	ModActionPostRollback    = "post.rollback"
+	ModActionUserEdit        = "user.edit"
+	ModActionUserBan         = "user.ban"
+	ModActionIPBan           = "ip.ban"
+	ModActionBanLift         = "ban.lift"
+	ModActionSessionRevoke   = "session.revoke"
+	ModActionTokenRevoke     = "token.revoke"
+	ModActionBoardCreate     = "board.create"
+	ModActionBoardUpdate     = "board.update"
+	ModActionBoardDelete     = "board.delete"
+	ModActionBoardRestore    = "board.restore"
+	ModActionBoardPurge      = "board.purge"
+	ModActionTrashPurge      = "trash.purge"
+	ModActionBoardAccess     = "board.access"
+	ModActionModeratorAdd    = "moderator.add"
+	ModActionModeratorRemove = "moderator.remove"
+	ModActionGroupCreate     = "group.create"
+	ModActionGroupUpdate     = "group.update"
+	ModActionGroupDelete     = "group.delete"
+	ModActionSettingsEdit    = "settings.edit"
+	ModActionWebhookCreate   = "webhook.create"
+	ModActionWebhookUpdate   = "webhook.update"
+	ModActionWebhookDelete   = "webhook.delete"
+	ModActionMessageRemove   = "message.remove"
+	ModActionMessageDismiss  = "message.dismiss"
+	ModActionReportDismiss   = "report.dismiss"
+	ModActionPostApprove     = "post.approve"
+	ModActionPostReject      = "post.reject"
+	ModActionPostSpam        = "post.spam"
+	ModActionPostNotSpam     = "post.not_spam"
+)
+
+const modLogPageSize = 50
+
+// A single entry in the mod log. Entries are only ever inserted; the
+// table refuses updates and deletes. Before and After hold JSON snapshots
+// of whatever the action changed.
+type ModLogEntry struct {
+	ID           int64          `db:"id"`
+	ActorID      int64          `db:"actor_id"`
+	Action       string         `db:"action"`
+	TargetType   string         `db:"target_type"`
+	TargetID     sql.NullInt64  `db:"target_id"`
+	TargetUserID sql.NullInt64  `db:"target_user_id"`
+	BoardID      sql.NullInt64  `db:"board_id"`
+	Before       sql.NullString `db:"before_value"`
+	After        sql.NullString `db:"after_value"`
+	Reason       string         `db:"reason"`
+	IP           string         `db:"ip"`
+	CreatedOn    time.Time      `db:"created_on"`
+}
+
+// Narrows down GetModLog. Zero values match everything.
+type ModLogFilter struct {
+	ActorID      int64
+	TargetUserID int64
+	BoardID      int64
+	From         time.Time
+	To           time.Time
+}
+
+func NewModLogEntry(actor *User, action, ip string) *ModLogEntry {
+	return &ModLogEntry{
+		ActorID:   actor.ID,
+		Action:    action,
+		IP:        ip,
+		CreatedOn: time.Now(),
+	}
+}
+
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: true}
+}
+
+// Points the entry at a post, or a thread if the post is an OP
+func (entry *ModLogEntry) OnPost(post *Post) *ModLogEntry {
+	entry.TargetType = "post"
+	if !post.ParentID.Valid {
+		entry.TargetType = "thread"
+	}
+
+	entry.TargetID = nullID(post.ID)
+	entry.TargetUserID = nullID(post.AuthorID)
+	entry.BoardID = nullID(post.BoardID)
+	return entry
+}
+
+func (entry *ModLogEntry) OnUser(user *User) *ModLogEntry {
+	entry.TargetType = "user"
+	entry.TargetID = nullID(user.ID)
+	entry.TargetUserID = nullID(user.ID)
+	return entry
+}
+
+func (entry *ModLogEntry) OnBoard(board *Board) *ModLogEntry {
+	entry.TargetType = "board"
+	entry.TargetID = nullID(board.ID)
+	entry.BoardID = nullID(board.ID)
+	return entry
+}
+
+func (entry *ModLogEntry) OnGroup(group *Group) *ModLogEntry {
+	entry.TargetType = "group"
+	entry.TargetID = nullID(group.ID)
+	return entry
+}
+
+func (entry *ModLogEntry) OnBan(ban *Ban) *ModLogEntry {
+	entry.TargetType = "ban"
+	entry.TargetID = nullID(ban.ID)
+	entry.TargetUserID = ban.UserID
+	return entry
+}
+
+func (entry *ModLogEntry) OnWebhook(webhook *Webhook) *ModLogEntry {
+	entry.TargetType = "webhook"
+	entry.TargetID = nullID(webhook.ID)
+	return entry
+}
+
+// Only points at the message. Its content goes in the entry when the
+// action needs it, so the log doesn't become a way to read messages.
+func (entry *ModLogEntry) OnMessage(message *Message) *ModLogEntry {
+	entry.TargetType = "message"
+	entry.TargetID = nullID(message.ID)
+	entry.TargetUserID = nullID(message.AuthorID)
+	return entry
+}
+
+func (entry *ModLogEntry) OnTrash() *ModLogEntry {
+	entry.TargetType = "trash"
+	return entry
+}
+
+func (entry *ModLogEntry) OnSettings() *ModLogEntry {
+	entry.TargetType = "settings"
+	return entry
+}
+
+func toLogValue(value interface{}) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: string(out), Valid: true}
+}
+
+// Records what the target looked like before and after the action. Either
+// may be nil.
+func (entry *ModLogEntry) Change(before, after interface{}) *ModLogEntry {
+	entry.Before = toLogValue(before)
+	entry.After = toLogValue(after)
+	return entry
+}
+
+func (entry *ModLogEntry) WithReason(reason string) *ModLogEntry {
+	entry.Reason = TruncateText(strings.TrimSpace(reason), 255)
+	return entry
+}
+
+// Writes the entry to the log. A failure to log never stops the action
+// itself, so errors are only reported on the console.
+func (entry *ModLogEntry) Save() {
+	db := GetDbSession()
+	if err := db.Insert(entry); err != nil {
+		fmt.Printf("[error] Could not write to the mod log (%s)\n", err.Error())
+	}
+}
+
+func (filter *ModLogFilter) where() (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.ActorID != 0 {
+		add("actor_id=$%d", filter.ActorID)
+	}
+	if filter.TargetUserID != 0 {
+		add("target_user_id=$%d", filter.TargetUserID)
+	}
+	if filter.BoardID != 0 {
+		add("board_id=$%d", filter.BoardID)
+	}
+	if !filter.From.IsZero() {
+		add("created_on >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_on < $%d", filter.To)
+	}
+
+	if len(clauses) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// Returns a page of matching entries, newest first
+func GetModLog(filter *ModLogFilter, page int) ([]*ModLogEntry, error) {
+	db := GetDbSession()
+	where, args := filter.where()
+
+	var entries []*ModLogEntry
+	_, err := db.Select(&entries, fmt.Sprintf("SELECT * FROM mod_log%s ORDER BY created_on DESC, id DESC LIMIT %d OFFSET %d", where, modLogPageSize, page*modLogPageSize), args...)
+
+	return entries, err
+}
+
+// Returns the number of pages of entries matching the filter
+func GetModLogPages(filter *ModLogFilter) int {
+	db := GetDbSession()
+	where, args := filter.where()
+
+	count, err := db.SelectInt("SELECT COUNT(*) FROM mod_log"+where, args...)
+	if err != nil {
+		return 0
+	}
+
+	return int((count + modLogPageSize - 1) / modLogPageSize)
+}
+
+func (entry *ModLogEntry) GetActor() *User {
+	user, _ := GetUser(int(entry.ActorID))
+	return user
+}
+
+func (entry *ModLogEntry) GetTargetUser() *User {
+	if !entry.TargetUserID.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(entry.TargetUserID.Int64))
+	return user
+}
+
+func (entry *ModLogEntry) GetBoard() *Board {
+	if !entry.BoardID.Valid {
+		return nil
+	}
+
+	board, _ := GetBoard(int(entry.BoardID.Int64))
+	return board
+}
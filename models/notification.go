@@ -0,0 +1,329 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Reasons someone is told about a post. When a post gives a user more than
+// one reason, they only hear about the first one in this order.
+const (
+	NotificationMention = "mention"
+	NotificationQuote   = "quote"
+	NotificationReply   = "reply"
+	// Sent whatever the user's settings
+	NotificationReport   = "report"
+	NotificationRejected = "rejected"
+)
+
+const notificationsPerPage = 30
+
+const (
+	// Only this many different people can be mentioned, and this many
+	// quoted, in one post. Any more are ignored.
+	maxMentionsPerPost = 20
+	// How many notifications are saved in one statement
+	notificationInsertBatch = 500
+)
+
+var (
+	// @username, as long as it isn't part of an email address
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.\-]+)`)
+	// The "username said:" line the quote button puts above a quote
+	quotePattern = regexp.MustCompile(`(?m)^(\S+) said:\s*$`)
+)
+
+// Tells a user that someone mentioned, quoted or replied to them
+type Notification struct {
+	ID        int64         `db:"id"`
+	UserID    int64         `db:"user_id"`
+	ActorID   int64         `db:"actor_id"`
+	PostID    int64         `db:"post_id"`
+	Type      string        `db:"type"`
+	Read      bool          `db:"read"`
+	Emailed   bool          `db:"emailed"`
+	ReportID  sql.NullInt64 `db:"report_id"`
+	CreatedOn time.Time     `db:"created_on"`
+}
+
+// Whether the user wants to be told about the given kind of notification
+func (user *User) WantsNotification(notificationType string) bool {
+	switch notificationType {
+	case NotificationMention:
+		return user.NotifyMentions
+	case NotificationQuote:
+		return user.NotifyQuotes
+	case NotificationReply:
+		return user.NotifyReplies
+	case NotificationReport, NotificationRejected:
+		return true
+	}
+
+	return false
+}
+
+// Returns the usernames mentioned in a post's content. Quoted lines are
+// skipped, so quoting a post doesn't mention everyone it mentioned.
+func getMentionedUsernames(content string) []string {
+	var usernames []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+
+		for _, match := range mentionPattern.FindAllStringSubmatch(line, -1) {
+			usernames = append(usernames, strings.TrimRight(match[1], ".-"))
+		}
+	}
+
+	return usernames
+}
+
+func getQuotedUsernames(content string) []string {
+	var usernames []string
+	for _, match := range quotePattern.FindAllStringSubmatch(content, -1) {
+		usernames = append(usernames, match[1])
+	}
+
+	return usernames
+}
+
+// Creates notifications for everyone a newly published post mentions,
+// quotes or replies to. Nobody is notified about their own posts or about
+// posts in boards they can't read.
+func CreatePostNotifications(post *Post) error {
+	db := GetDbSession()
+
+	// Every reason each user has to hear about the post, in priority order
+	var recipients []int64
+	reasons := map[int64][]string{}
+	addReason := func(userID int64, notificationType string) {
+		if userID == post.AuthorID {
+			return
+		}
+		if _, ok := reasons[userID]; !ok {
+			recipients = append(recipients, userID)
+		}
+		reasons[userID] = append(reasons[userID], notificationType)
+	}
+
+	mentioned := uniqueUsernames(getMentionedUsernames(post.Content))
+	quoted := uniqueUsernames(getQuotedUsernames(post.Content))
+	named, err := getUsersByUsername(append(mentioned, quoted...))
+	if err != nil {
+		return err
+	}
+
+	users := map[int64]*User{}
+	for _, user := range named {
+		users[user.ID] = user
+	}
+
+	for _, username := range mentioned {
+		if user, ok := named[username]; ok {
+			addReason(user.ID, NotificationMention)
+		}
+	}
+	for _, username := range quoted {
+		if user, ok := named[username]; ok {
+			addReason(user.ID, NotificationQuote)
+		}
+	}
+
+	// Replies go to everyone who started, has posted in or is watching
+	// the thread
+	if post.ParentID.Valid {
+		var repliedTo []*User
+		_, err := db.Select(&repliedTo, `
+            SELECT * FROM users WHERE id IN (
+                SELECT author_id FROM posts WHERE (id=$1 OR parent_id=$1) AND deleted_at IS NULL AND NOT pending
+                UNION
+                SELECT user_id FROM thread_subscriptions WHERE thread_id=$1
+            )
+        `, post.ParentID.Int64)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range repliedTo {
+			users[user.ID] = user
+			addReason(user.ID, NotificationReply)
+		}
+	}
+
+	board, err := GetBoard(int(post.BoardID))
+	if err != nil || board == nil {
+		return err
+	}
+
+	now := time.Now()
+	var notifications []*Notification
+	for _, userID := range recipients {
+		user := users[userID]
+		if user == nil || !Can(user, PermBoardRead, board) {
+			continue
+		}
+
+		for _, notificationType := range reasons[userID] {
+			if !user.WantsNotification(notificationType) {
+				continue
+			}
+
+			notifications = append(notifications, &Notification{
+				UserID:    user.ID,
+				ActorID:   post.AuthorID,
+				PostID:    post.ID,
+				Type:      notificationType,
+				CreatedOn: now,
+			})
+			break
+		}
+	}
+
+	return insertNotifications(notifications)
+}
+
+// Drops repeated usernames, keeping at most maxMentionsPerPost of them so
+// that one post can't notify half the forum
+func uniqueUsernames(usernames []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, username := range usernames {
+		if seen[username] {
+			continue
+		}
+		if len(unique) == maxMentionsPerPost {
+			break
+		}
+
+		seen[username] = true
+		unique = append(unique, username)
+	}
+
+	return unique
+}
+
+// Saves new notifications a few hundred to a statement, rather than one
+// at a time
+func insertNotifications(notifications []*Notification) error {
+	db := GetDbSession()
+	for len(notifications) > 0 {
+		batch := notifications
+		if len(batch) > notificationInsertBatch {
+			batch = batch[:notificationInsertBatch]
+		}
+		notifications = notifications[len(batch):]
+
+		rows := make([]string, len(batch))
+		var args []interface{}
+		for i, notification := range batch {
+			n := len(args)
+			rows[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+			args = append(args, notification.UserID, notification.ActorID, notification.PostID, notification.Type, notification.CreatedOn)
+		}
+
+		_, err := db.Exec("INSERT INTO notifications (user_id, actor_id, post_id, type, created_on) VALUES "+strings.Join(rows, ", "), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Only notifications about posts the user can still see are shown. Reports
+// and rejections are the exception, since the post is often gone by the
+// time they're sent.
+func notificationVisibleSQL(user *User) string {
+	return "(notifications.report_id IS NOT NULL OR notifications.type='" + NotificationRejected + "' OR notifications.post_id IN (SELECT posts.id FROM posts WHERE posts.deleted_at IS NULL AND NOT posts.pending AND " + postReadableSQL(user) + "))"
+}
+
+func GetNotification(ID int) (*Notification, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Notification{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Notification), err
+}
+
+// Returns a page of the user's notifications, newest first, along with the
+// number of pages
+func (user *User) GetNotifications(page int) ([]*Notification, int, error) {
+	db := GetDbSession()
+
+	count, err := db.SelectInt("SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND "+notificationVisibleSQL(user), user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var notifications []*Notification
+	_, err = db.Select(&notifications, "SELECT * FROM notifications WHERE user_id=$1 AND "+notificationVisibleSQL(user)+" ORDER BY created_on DESC, id DESC LIMIT $2 OFFSET $3", user.ID, notificationsPerPage, page*notificationsPerPage)
+
+	pages := int(math.Ceil(float64(count) / float64(notificationsPerPage)))
+	return notifications, pages, err
+}
+
+func (user *User) CountUnreadNotifications() int64 {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND NOT read AND "+notificationVisibleSQL(user), user.ID)
+	if err != nil {
+		fmt.Printf("[error] Could not count notifications (%s)\n", err.Error())
+		return 0
+	}
+
+	return count
+}
+
+func (user *User) MarkAllNotificationsRead() error {
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE notifications SET read=TRUE WHERE user_id=$1 AND NOT read", user.ID)
+	return err
+}
+
+func (notification *Notification) MarkRead() error {
+	if notification.Read {
+		return nil
+	}
+
+	notification.Read = true
+
+	db := GetDbSession()
+	_, err := db.Update(notification)
+	return err
+}
+
+func (notification *Notification) GetActor() *User {
+	user, _ := GetUser(int(notification.ActorID))
+	return user
+}
+
+func (notification *Notification) GetReport() *PostReport {
+	if !notification.ReportID.Valid {
+		return nil
+	}
+
+	report, _ := GetPostReport(int(notification.ReportID.Int64))
+	return report
+}
+
+func (notification *Notification) GetPost() *Post {
+	post, _ := GetPost(int(notification.PostID))
+	return post
+}
+
+// Returns the thread the notification's post is in
+func (notification *Notification) GetThread() *Post {
+	post := notification.GetPost()
+	if post == nil || !post.ParentID.Valid {
+		return post
+	}
+
+	thread, _ := GetPost(int(post.ParentID.Int64))
+	return thread
+}
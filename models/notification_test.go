@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetMentionedUsernames(t *testing.T) {
+	content := "Thanks @alice and @bob.\n> @carol said this first\nmail me at dave@example.com"
+
+	got := getMentionedUsernames(content)
+	want := []string{"alice", "bob"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getMentionedUsernames() = %v, want %v", got, want)
+	}
+}
+
+func TestUniqueUsernamesIsCapped(t *testing.T) {
+	var mentions []string
+	for i := 0; i < maxMentionsPerPost*3; i++ {
+		mentions = append(mentions, fmt.Sprintf("@user%d", i), "@user0")
+	}
+
+	got := uniqueUsernames(getMentionedUsernames(strings.Join(mentions, " ")))
+	if len(got) != maxMentionsPerPost {
+		t.Fatalf("Got %d usernames, want %d", len(got), maxMentionsPerPost)
+	}
+	if got[0] != "user0" || got[1] != "user1" {
+		t.Errorf("Usernames weren't kept in the order they were mentioned: %v", got)
+	}
+}
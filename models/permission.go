@@ -0,0 +1,154 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Names of every capability a group can be given. These must match the
+// rows in the permissions table.
+const (
+	PermPostCreate      = "post.create"
+	PermPostReply       = "post.reply"
+	PermPostEdit        = "post.edit"
+	PermPostDelete      = "post.delete"
+	PermThreadStick     = "thread.stick"
+	PermThreadLock      = "thread.lock"
+	PermThreadMove      = "thread.move"
+	PermBoardManage     = "board.manage"
+	PermUserManage      = "user.manage"
+	PermUserBan         = "user.ban"
+	PermIPBan           = "ip.ban"
+	PermGroupManage     = "group.manage"
+	PermSettingsEdit    = "settings.edit"
+	PermModLogView      = "modlog.view"
+	PermTrashPurge      = "trash.purge"
+	PermWebhookManage   = "webhooks.manage"
+	PermMessageModerate = "messages.moderate"
+	PermReportHandle    = "reports.handle"
+	PermPostApprove     = "post.approve"
+
+	// Not a group permission: whether a board can be seen at all is
+	// decided by the board's own access rules.
+	PermBoardRead = "board.read"
+)
+
+type Permission struct {
+	Name        string `db:"name"`
+	Description string `db:"description"`
+}
+
+type GroupPermission struct {
+	GroupID    int64  `db:"group_id"`
+	Permission string `db:"permission"`
+}
+
+// Permissions are checked several times on every page, so each group's
+// set is cached until it is changed through Group.SetPermissions.
+var (
+	permissionCache     = map[int64]map[string]bool{}
+	permissionCacheLock sync.RWMutex
+)
+
+func GetPermissions() ([]*Permission, error) {
+	db := GetDbSession()
+
+	var permissions []*Permission
+	_, err := db.Select(&permissions, "SELECT * FROM permissions ORDER BY name ASC")
+
+	return permissions, err
+}
+
+func getGroupPermissionSet(groupID int64) map[string]bool {
+	permissionCacheLock.RLock()
+	set, ok := permissionCache[groupID]
+	permissionCacheLock.RUnlock()
+	if ok {
+		return set
+	}
+
+	db := GetDbSession()
+	var granted []*GroupPermission
+	_, err := db.Select(&granted, "SELECT * FROM group_permissions WHERE group_id=$1", groupID)
+	if err != nil {
+		fmt.Printf("[error] Could not get permissions for group %d (%s)\n", groupID, err.Error())
+		return map[string]bool{}
+	}
+
+	set = map[string]bool{}
+	for _, g := range granted {
+		set[g.Permission] = true
+	}
+
+	permissionCacheLock.Lock()
+	permissionCache[groupID] = set
+	permissionCacheLock.Unlock()
+
+	return set
+}
+
+func clearPermissionCache() {
+	permissionCacheLock.Lock()
+	permissionCache = map[int64]map[string]bool{}
+	permissionCacheLock.Unlock()
+}
+
+// Reports whether the user may do something. This is the one place every
+// permission check in gobb goes through. The board is the board the
+// action happens on, or nil for site-wide actions. Visitors who aren't
+// logged in (a nil user) can't do anything that needs a permission.
+//
+// When a board is given its access rules apply on top of the group's
+// permissions: nothing at all can be done on a board the user can't read,
+// and starting threads or replying also needs the board's consent. The
+// board's own moderators get the moderation permissions there no matter
+// which group they're in.
+func Can(user *User, permission string, board *Board) bool {
+	if board != nil {
+		access := board.GetAccess(user)
+		if !access.CanRead {
+			return false
+		}
+
+		switch permission {
+		case PermBoardRead:
+			return true
+		case PermPostCreate:
+			if !access.CanPost {
+				return false
+			}
+		case PermPostReply:
+			if !access.CanReply {
+				return false
+			}
+		}
+	} else if permission == PermBoardRead {
+		return false
+	}
+
+	if user == nil {
+		return false
+	}
+
+	if board != nil && boardModeratorPermissions[permission] && board.IsModerator(user) {
+		return true
+	}
+
+	return getGroupPermissionSet(user.GroupID)[permission]
+}
+
+// Same as Can, but true if the user has any of the permissions
+func CanAny(user *User, board *Board, permissions ...string) bool {
+	for _, permission := range permissions {
+		if Can(user, permission, board) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the user should see the admin panel at all
+func (user *User) HasAdminAccess() bool {
+	return CanAny(user, nil, PermSettingsEdit, PermBoardManage, PermUserManage, PermGroupManage, PermModLogView, PermWebhookManage, PermMessageModerate)
+}
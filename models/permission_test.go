@@ -0,0 +1,176 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Fills the permission cache so Can can be tested without a database
+func setGroupPermissions(t *testing.T, groups map[int64][]string) {
+	clearPermissionCache()
+	t.Cleanup(clearPermissionCache)
+
+	permissionCacheLock.Lock()
+	defer permissionCacheLock.Unlock()
+	for groupID, permissions := range groups {
+		set := map[string]bool{}
+		for _, permission := range permissions {
+			set[permission] = true
+		}
+		permissionCache[groupID] = set
+	}
+}
+
+func TestCanSiteWide(t *testing.T) {
+	setGroupPermissions(t, map[int64][]string{
+		DefaultGroupID:       {PermPostCreate, PermPostReply},
+		AdministratorGroupID: {PermPostCreate, PermPostReply, PermSettingsEdit, PermUserBan},
+	})
+
+	member := &User{ID: 1, GroupID: DefaultGroupID}
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+
+	tests := []struct {
+		user       *User
+		permission string
+		want       bool
+	}{
+		{nil, PermPostCreate, false},
+		{member, PermPostCreate, true},
+		{member, PermSettingsEdit, false},
+		{member, PermUserBan, false},
+		{admin, PermSettingsEdit, true},
+		{admin, PermUserBan, true},
+		{admin, "no.such.permission", false},
+		// Reading is only ever decided by a board
+		{admin, PermBoardRead, false},
+	}
+
+	for _, test := range tests {
+		if got := Can(test.user, test.permission, nil); got != test.want {
+			t.Errorf("Can(%v, %q, nil) = %v, want %v", test.user, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestCanAny(t *testing.T) {
+	setGroupPermissions(t, map[int64][]string{
+		DefaultGroupID: {PermPostReply},
+	})
+
+	member := &User{ID: 1, GroupID: DefaultGroupID}
+	if !CanAny(member, nil, PermSettingsEdit, PermPostReply) {
+		t.Error("CanAny ignored a permission the user has")
+	}
+	if CanAny(member, nil, PermSettingsEdit, PermUserBan) {
+		t.Error("CanAny allowed permissions the user doesn't have")
+	}
+	if member.HasAdminAccess() {
+		t.Error("A member without admin permissions has admin access")
+	}
+}
+
+// Makes a board whose access for each group is already known, so Can
+// doesn't need a database to look it up
+func newTestBoard(access ...*BoardAccess) *Board {
+	board := &Board{
+		ID:             1,
+		accessCache:    map[int64]*BoardAccess{},
+		moderatorCache: map[int64]bool{},
+	}
+	for _, a := range access {
+		a.BoardID = board.ID
+		board.accessCache[a.GroupID] = a
+	}
+
+	return board
+}
+
+func TestCanOnBoard(t *testing.T) {
+	setGroupPermissions(t, map[int64][]string{
+		DefaultGroupID:       {PermPostCreate, PermPostReply},
+		AdministratorGroupID: {PermPostCreate, PermPostReply, PermPostDelete},
+	})
+
+	member := &User{ID: 1, GroupID: DefaultGroupID}
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+
+	// Members may read and reply but not start threads; admins are shut
+	// out entirely
+	board := newTestBoard(
+		&BoardAccess{GroupID: DefaultGroupID, CanRead: true, CanReply: true},
+		&BoardAccess{GroupID: AdministratorGroupID},
+	)
+	board.GuestRead = true
+
+	tests := []struct {
+		user       *User
+		permission string
+		want       bool
+	}{
+		{nil, PermBoardRead, true},
+		{nil, PermPostReply, false},
+		{member, PermBoardRead, true},
+		{member, PermPostReply, true},
+		{member, PermPostCreate, false},
+		{member, PermPostDelete, false},
+		{admin, PermBoardRead, false},
+		{admin, PermPostDelete, false},
+	}
+
+	for _, test := range tests {
+		if got := Can(test.user, test.permission, board); got != test.want {
+			t.Errorf("Can(%v, %q, board) = %v, want %v", test.user, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestBoardReadableSQL(t *testing.T) {
+	guest := boardReadableSQL(nil)
+	if !strings.Contains(guest, "boards.guest_read") {
+		t.Errorf("Guest filter doesn't check guest_read: %s", guest)
+	}
+
+	member := boardReadableSQL(&User{ID: 5, GroupID: 7})
+	for _, want := range []string{"board_access.group_id=7", "boards.default_read"} {
+		if !strings.Contains(member, want) {
+			t.Errorf("Member filter is missing %q: %s", want, member)
+		}
+	}
+	if strings.Contains(member, "guest_read") {
+		t.Errorf("Member filter uses the guest rule: %s", member)
+	}
+
+	for _, filter := range []string{guest, member} {
+		if !strings.Contains(filter, "boards.deleted_at IS NULL") {
+			t.Errorf("Filter lets boards in the trash through: %s", filter)
+		}
+	}
+
+	if posts := postReadableSQL(nil); !strings.Contains(posts, guest) {
+		t.Errorf("Post filter doesn't use the board filter: %s", posts)
+	}
+}
+
+func TestCanOnDeletedBoard(t *testing.T) {
+	setGroupPermissions(t, map[int64][]string{
+		AdministratorGroupID: {PermPostReply, PermPostDelete},
+	})
+
+	admin := &User{ID: 2, GroupID: AdministratorGroupID}
+	board := newTestBoard(&BoardAccess{GroupID: AdministratorGroupID, CanRead: true, CanReply: true})
+	board.GuestRead = true
+	board.DeletedAt = pq.NullTime{Time: time.Now(), Valid: true}
+
+	for _, permission := range []string{PermBoardRead, PermPostReply, PermPostDelete} {
+		if Can(admin, permission, board) {
+			t.Errorf("Can(admin, %q) allowed on a board in the trash", permission)
+		}
+	}
+	if Can(nil, PermBoardRead, board) {
+		t.Error("Guests can read a board in the trash")
+	}
+}
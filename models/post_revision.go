@@ -0,0 +1,197 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A saved version of a post. The first revision of an edited post is the
+// post as it was originally written; every edit after that adds another.
+// Posts which have never been edited have no revisions at all.
+type PostRevision struct {
+	ID          int64     `db:"id"`
+	PostID      int64     `db:"post_id"`
+	EditorID    int64     `db:"editor_id"`
+	Title       string    `db:"title"`
+	Content     string    `db:"content"`
+	Reason      string    `db:"reason"`
+	ByModerator bool      `db:"by_moderator"`
+	CreatedOn   time.Time `db:"created_on"`
+}
+
+func GetPostRevision(ID int) (*PostRevision, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&PostRevision{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*PostRevision), err
+}
+
+// Returns every version of the post, oldest first
+func (post *Post) GetRevisions() ([]*PostRevision, error) {
+	db := GetDbSession()
+
+	var revisions []*PostRevision
+	_, err := db.Select(&revisions, "SELECT * FROM post_revisions WHERE post_id=$1 ORDER BY created_on ASC, id ASC", post.ID)
+
+	return revisions, err
+}
+
+// What a page of posts shows about each post's edits
+type postEdits struct {
+	count  int
+	latest *PostRevision
+}
+
+type revisionCount struct {
+	PostID int64 `db:"post_id"`
+	Count  int   `db:"count"`
+}
+
+// Looks up the edit counts and latest edits of a page of posts in one go,
+// rather than once per post as the page is drawn
+func LoadEditHistory(posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := map[int64]*Post{}
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, len(posts))
+	for i, post := range posts {
+		byID[post.ID] = post
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = post.ID
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+
+	db := GetDbSession()
+	var counts []*revisionCount
+	_, err := db.Select(&counts, "SELECT post_id, COUNT(*) AS count FROM post_revisions WHERE post_id IN "+in+" GROUP BY post_id", args...)
+	if err != nil {
+		return err
+	}
+
+	var latest []*PostRevision
+	_, err = db.Select(&latest, "SELECT DISTINCT ON (post_id) * FROM post_revisions WHERE post_id IN "+in+" ORDER BY post_id, created_on DESC, id DESC", args...)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.edits = &postEdits{}
+	}
+
+	// The first revision is the original post
+	for _, count := range counts {
+		if post, ok := byID[count.PostID]; ok && count.Count > 1 {
+			post.edits.count = count.Count - 1
+		}
+	}
+
+	for _, revision := range latest {
+		if post, ok := byID[revision.PostID]; ok && post.edits.count > 0 {
+			post.edits.latest = revision
+		}
+	}
+
+	return nil
+}
+
+// Returns the number of times the post has been edited
+func (post *Post) GetEditCount() int {
+	if post.edits != nil {
+		return post.edits.count
+	}
+
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM post_revisions WHERE post_id=$1", post.ID)
+	if err != nil || count == 0 {
+		return 0
+	}
+
+	// The first revision is the original post
+	return int(count - 1)
+}
+
+// Returns the most recent edit, or nil if the post was never edited
+func (post *Post) GetLatestRevision() *PostRevision {
+	if post.edits != nil {
+		return post.edits.latest
+	}
+
+	db := GetDbSession()
+	revision := &PostRevision{}
+	err := db.SelectOne(revision, "SELECT * FROM post_revisions WHERE post_id=$1 ORDER BY created_on DESC, id DESC LIMIT 1", post.ID)
+	if err != nil || revision.ID == 0 {
+		return nil
+	}
+
+	return revision
+}
+
+// Changes the post's title and content, keeping the old version in its
+// history. The post should be validated first.
+func (post *Post) SaveEdit(editor *User, title, content, reason string) error {
+	reason = TruncateText(reason, 255)
+
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Posts written before revisions existed, and posts being edited for
+	// the first time, need their original version saved
+	count, err := tx.SelectInt("SELECT COUNT(*) FROM post_revisions WHERE post_id=$1", post.ID)
+	if err == nil && count == 0 {
+		err = tx.Insert(&PostRevision{
+			PostID:    post.ID,
+			EditorID:  post.AuthorID,
+			Title:     post.Title,
+			Content:   post.Content,
+			CreatedOn: post.CreatedOn,
+		})
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	now := time.Now()
+	post.Title = title
+	post.Content = content
+	post.LastEdit = now
+
+	_, err = tx.Update(post)
+	if err == nil {
+		err = updateSearchIndex(tx, post)
+	}
+	if err == nil {
+		err = tx.Insert(&PostRevision{
+			PostID:      post.ID,
+			EditorID:    editor.ID,
+			Title:       title,
+			Content:     content,
+			Reason:      reason,
+			ByModerator: editor.ID != post.AuthorID,
+			CreatedOn:   now,
+		})
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (revision *PostRevision) GetEditor() *User {
+	user, _ := GetUser(int(revision.EditorID))
+	return user
+}
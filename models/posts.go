@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/coopernurse/gorp"
+	"github.com/lib/pq"
 	"github.com/stevenleeg/gobb/config"
 )
 
@@ -24,12 +25,31 @@ type Post struct {
 	LastEdit    time.Time     `db:"last_edit"`
 	Sticky      bool          `db:"sticky"`
 	Locked      bool          `db:"locked"`
+
+	// Waiting for a moderator to approve it. See approval.go.
+	Pending bool `db:"pending"`
+
+	// What the spam filter made of the post when it was published, what
+	// it looked at to decide, and whether that's why it's pending. See
+	// spam.go.
+	SpamScore    sql.NullFloat64 `db:"spam_score"`
+	SpamFeatures string          `db:"spam_features"`
+	SpamHeld     bool            `db:"spam_held"`
+
+	// Set when the post is in the trash
+	DeletedAt    pq.NullTime   `db:"deleted_at"`
+	DeletedBy    sql.NullInt64 `db:"deleted_by"`
+	DeleteReason string        `db:"delete_reason"`
+
+	// Edit history loaded along with a page of posts. See LoadEditHistory.
+	edits *postEdits `db:"-"`
 }
 
 // Initializes a new struct, adds some data, and returns the pointer to it
 func NewPost(author *User, board *Board, title, content string) *Post {
 	post := &Post{
 		BoardID:   board.ID,
+		Author:    author,
 		AuthorID:  author.ID,
 		Title:     title,
 		Content:   content,
@@ -56,7 +76,7 @@ func GetThread(parentID, pageID int) (error, *Post, []*Post) {
 	db := GetDbSession()
 
 	op, err := db.Get(Post{}, parentID)
-	if err != nil || op == nil {
+	if err != nil || op == nil || op.(*Post).IsDeleted() {
 		fmt.Printf("Something weird is going on here: parentID: %d, pageID: %d", parentID, pageID)
 		return fmt.Errorf("[error] Could not get parent (%d)", parentID), nil, nil
 	}
@@ -74,16 +94,20 @@ func GetThread(parentID, pageID int) (error, *Post, []*Post) {
 	}
 
 	var childPosts []*Post
-	db.Select(&childPosts, "SELECT * FROM posts WHERE parent_id=$1 ORDER BY created_on ASC LIMIT $2 OFFSET $3", parentID, postsPerPage, i_begin)
+	db.Select(&childPosts, "SELECT * FROM posts WHERE parent_id=$1 AND deleted_at IS NULL AND NOT pending ORDER BY created_on ASC, id ASC LIMIT $2 OFFSET $3", parentID, postsPerPage, i_begin)
+
+	if err := LoadEditHistory(append([]*Post{op.(*Post)}, childPosts...)); err != nil {
+		fmt.Printf("[error] Could not get edit history (%s)\n", err.Error())
+	}
 
 	return nil, op.(*Post), childPosts
 }
 
-// Returns the number of posts (on every board/thread)
-func GetPostCount() (int64, error) {
+// Returns the number of posts on every board the user is allowed to read
+func GetPostCount(user *User) (int64, error) {
 	db := GetDbSession()
 
-	count, err := db.SelectInt("SELECT COUNT(*) FROM posts")
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL AND NOT pending AND " + postReadableSQL(user))
 	if err != nil {
 		fmt.Printf("[error] Error selecting post count (%s)\n", err.Error())
 		return 0, errors.New("Database error: " + err.Error())
@@ -92,6 +116,17 @@ func GetPostCount() (int64, error) {
 	return count, nil
 }
 
+// Returns the newest threads and replies across every board the user is
+// allowed to read
+func GetRecentPosts(user *User, limit int) ([]*Post, error) {
+	db := GetDbSession()
+
+	var posts []*Post
+	_, err := db.Select(&posts, "SELECT * FROM posts WHERE deleted_at IS NULL AND NOT pending AND "+postReadableSQL(user)+" ORDER BY created_on DESC LIMIT $1", limit)
+
+	return posts, err
+}
+
 // Post-SELECT hook for gorp which adds a pointer to the author
 // to the Post's struct
 func (post *Post) PostGet(s gorp.SqlExecutor) error {
@@ -107,6 +142,9 @@ func (post *Post) PostGet(s gorp.SqlExecutor) error {
 	return nil
 }
 
+// The longest a post or private message can be, in bytes
+const maxContentLength = 65536
+
 // Ensures that a post is valid
 func (post *Post) Validate() error {
 	if post.BoardID == 0 {
@@ -117,6 +155,10 @@ func (post *Post) Validate() error {
 		return errors.New("Post must be longer than three characters")
 	}
 
+	if len(post.Content) > maxContentLength {
+		return fmt.Errorf("Posts must be at most %d characters", maxContentLength)
+	}
+
 	if !post.ParentID.Valid && len(post.Title) <= 3 {
 		return errors.New("Post title must be longer than three characters")
 	}
@@ -124,6 +166,116 @@ func (post *Post) Validate() error {
 	return nil
 }
 
+// Checks a new title and content for the post without changing it
+func (post *Post) ValidateEdit(title, content string) error {
+	edited := *post
+	edited.Title = title
+	edited.Content = content
+
+	return edited.Validate()
+}
+
+// Validates and saves a new thread or reply. Replies bump their thread
+// to the top of its board, and anyone the post mentions, quotes or replies
+// to is notified. If the board holds the author's posts for approval, or
+// the spam filter thinks it's spam, the post is saved as pending instead,
+// and none of that happens until a moderator approves it.
+func (post *Post) Publish() error {
+	if err := post.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	post.CreatedOn = now
+	post.LatestReply = now
+
+	board, _ := GetBoard(int(post.BoardID))
+	author, _ := GetUser(int(post.AuthorID))
+	if board != nil && author != nil {
+		post.Pending = board.NeedsApproval(author)
+		post.checkSpam(author, board)
+	}
+
+	// Save the post and its search entry together, so a post which fails
+	// to index isn't left behind for the author to post again
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.Insert(post)
+	if err == nil {
+		err = updateSearchIndex(tx, post)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	if post.Pending {
+		return nil
+	}
+
+	return post.announce()
+}
+
+// Bumps the post's thread and notifies people about it once it's visible
+// to everyone
+func (post *Post) announce() error {
+	if post.ParentID.Valid {
+		db := GetDbSession()
+		_, err := db.Exec("UPDATE posts SET latest_reply=$1 WHERE id=$2", post.CreatedOn, post.ParentID.Int64)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A reply in a busy thread can notify thousands of watchers, so that
+	// happens off the request. The post is already saved, so failing to
+	// notify people isn't fatal.
+	published := *post
+	go func() {
+		if err := CreatePostNotifications(&published); err != nil {
+			fmt.Printf("[error] Could not create notifications for post %d (%s)\n", published.ID, err.Error())
+		}
+	}()
+
+	return nil
+}
+
+func (post *Post) SetSticky(sticky bool) error {
+	post.Sticky = sticky
+
+	db := GetDbSession()
+	_, err := db.Update(post)
+	return err
+}
+
+func (post *Post) SetLocked(locked bool) error {
+	post.Locked = locked
+
+	db := GetDbSession()
+	_, err := db.Update(post)
+	return err
+}
+
+// Moves a thread and all of its replies to another board
+func (post *Post) MoveTo(board *Board) error {
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE posts SET board_id=$1 WHERE id=$2 OR parent_id=$2", board.ID, post.ID)
+	if err != nil {
+		return err
+	}
+
+	post.BoardID = board.ID
+	return nil
+}
+
 // This is used primarily for threads. It will find the latest
 // post in a thread, allowing for things like "last post was 10
 // minutes ago.
@@ -131,7 +283,7 @@ func (post *Post) GetLatestPost() *Post {
 	db := GetDbSession()
 	latest := &Post{}
 
-	db.SelectOne(latest, "SELECT * FROM posts WHERE parent_id=$1 ORDER BY created_on DESC LIMIT 1", post.ID)
+	db.SelectOne(latest, "SELECT * FROM posts WHERE parent_id=$1 AND deleted_at IS NULL AND NOT pending ORDER BY created_on DESC LIMIT 1", post.ID)
 
 	return latest
 }
@@ -140,7 +292,7 @@ func (post *Post) GetLatestPost() *Post {
 // post structs that have ParentIds.
 func (post *Post) GetPagesInThread() int {
 	db := GetDbSession()
-	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE parent_id=$1", post.ID)
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE parent_id=$1 AND deleted_at IS NULL AND NOT pending", post.ID)
 
 	if err != nil {
 		fmt.Printf("[error] Could not get post count (%s)\n", err.Error())
@@ -160,7 +312,9 @@ func (post *Post) GetPagesInThread() int {
 }
 
 // This function tells us which page this particular post is in
-// within a thread based on the current value of posts_per_page
+// within a thread based on the current value of posts_per_page. Replies
+// are counted in the order GetThread shows them, which for approved posts
+// is when they were approved rather than when they were written.
 func (post *Post) GetPageInThread() int {
 	postsPerPage, err := config.Config.GetInt64("gobb", "posts_per_page")
 	if err != nil {
@@ -171,8 +325,8 @@ func (post *Post) GetPageInThread() int {
 	n, err := db.SelectInt(`
         WITH thread AS (
                 SELECT posts.*,
-                ROW_NUMBER() OVER(ORDER BY posts.id) AS position
-                FROM posts WHERE parent_id=$1)
+                ROW_NUMBER() OVER(ORDER BY posts.created_on, posts.id) AS position
+                FROM posts WHERE parent_id=$1 AND deleted_at IS NULL AND NOT pending)
         SELECT 
             posts.position
         FROM 
@@ -185,15 +339,6 @@ func (post *Post) GetPageInThread() int {
 	return int(math.Floor(float64(n) / float64(postsPerPage)))
 }
 
-// Used when deleting a thread. This deletes all posts who are
-// children of the OP.
-func (post *Post) DeleteAllChildren() error {
-	db := GetDbSession()
-
-	_, err := db.Exec("DELETE FROM posts WHERE parent_id=$1", post.ID)
-	return err
-}
-
 // Get the thread id for a post
 func (post *Post) GetThreadID() int64 {
 	if post.ParentID.Valid {
@@ -203,7 +348,13 @@ func (post *Post) GetThreadID() int64 {
 	}
 }
 
-// Generate a link to a post
+// Generate a link to a post. Pending posts are shown at the end of the
+// thread.
 func (post *Post) GetLink() string {
+	if post.Pending && post.ParentID.Valid {
+		thread := &Post{ID: post.ParentID.Int64}
+		return fmt.Sprintf("/board/%d/%d?page=%d#post_%d", post.BoardID, post.ParentID.Int64, thread.GetPagesInThread(), post.ID)
+	}
+
 	return fmt.Sprintf("/board/%d/%d?page=%d#post_%d", post.BoardID, post.GetThreadID(), post.GetPageInThread(), post.ID)
 }
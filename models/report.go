@@ -0,0 +1,227 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of problem a post can be reported for
+var ReportCategories = []string{
+	"spam",
+	"harassment",
+	"off-topic",
+	"illegal",
+	"other",
+}
+
+// Where a report is at. Everything other than open says what the
+// moderator who handled it did.
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportDeleted   = "deleted"
+	ReportLocked    = "locked"
+	ReportBanned    = "banned"
+)
+
+// A user flagging a post for the moderators
+type PostReport struct {
+	ID         int64         `db:"id"`
+	PostID     int64         `db:"post_id"`
+	ReporterID int64         `db:"reporter_id"`
+	Category   string        `db:"category"`
+	Reason     string        `db:"reason"`
+	Status     string        `db:"status"`
+	HandledBy  sql.NullInt64 `db:"handled_by"`
+	HandledOn  pq.NullTime   `db:"handled_on"`
+	CreatedOn  time.Time     `db:"created_on"`
+}
+
+// A post in the moderator queue along with every open report about it
+type ReportedPost struct {
+	Post    *Post
+	Reports []*PostReport
+}
+
+// Reports a post to the moderators. Reporting a post again updates the
+// earlier report while it's still open. Once a moderator has handled it,
+// the same user can't report the post again.
+func ReportPost(reporter *User, post *Post, category, reason string) error {
+	valid := false
+	for _, c := range ReportCategories {
+		if c == category {
+			valid = true
+		}
+	}
+	if !valid {
+		return errors.New("Please pick what's wrong with the post")
+	}
+
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 1024 {
+		return errors.New("Reasons must be at most 1024 characters")
+	}
+	if post.AuthorID == reporter.ID {
+		return errors.New("You can't report your own post")
+	}
+
+	db := GetDbSession()
+	report := &PostReport{}
+	err := db.SelectOne(report, "SELECT * FROM post_reports WHERE post_id=$1 AND reporter_id=$2", post.ID, reporter.ID)
+	if err == nil {
+		if report.Status != ReportOpen {
+			return errors.New("You've already reported this post and a moderator has looked at it")
+		}
+
+		report.Category = category
+		report.Reason = reason
+		_, err = db.Update(report)
+		return err
+	}
+
+	return db.Insert(&PostReport{
+		PostID:     post.ID,
+		ReporterID: reporter.ID,
+		Category:   category,
+		Reason:     reason,
+		Status:     ReportOpen,
+		CreatedOn:  time.Now(),
+	})
+}
+
+func GetPostReport(ID int) (*PostReport, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&PostReport{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*PostReport), err
+}
+
+// Returns the reported posts in boards where the user can handle reports,
+// the one reported longest ago first
+func GetReportQueue(user *User) ([]*ReportedPost, error) {
+	db := GetDbSession()
+
+	var reports []*PostReport
+	_, err := db.Select(&reports, "SELECT * FROM post_reports WHERE status=$1 ORDER BY created_on ASC, id ASC", ReportOpen)
+	if err != nil {
+		return nil, err
+	}
+
+	boards := map[int64]*Board{}
+	byPost := map[int64]*ReportedPost{}
+	var queue []*ReportedPost
+	for _, report := range reports {
+		if reported, ok := byPost[report.PostID]; ok {
+			reported.Reports = append(reported.Reports, report)
+			continue
+		}
+
+		post, _ := GetPost(int(report.PostID))
+		if post == nil {
+			continue
+		}
+
+		board, ok := boards[post.BoardID]
+		if !ok {
+			board, _ = GetBoard(int(post.BoardID))
+			boards[post.BoardID] = board
+		}
+		if board == nil || !Can(user, PermReportHandle, board) {
+			continue
+		}
+
+		reported := &ReportedPost{Post: post, Reports: []*PostReport{report}}
+		byPost[post.ID] = reported
+		queue = append(queue, reported)
+	}
+
+	return queue, nil
+}
+
+// Whether the user should see the report queue at all
+func (user *User) CanHandleReports() bool {
+	if Can(user, PermReportHandle, nil) {
+		return true
+	}
+
+	db := GetDbSession()
+	count, _ := db.SelectInt("SELECT COUNT(*) FROM board_moderators WHERE user_id=$1", user.ID)
+	return count > 0
+}
+
+// How many posts are waiting in the user's report queue
+func (user *User) CountReportedPosts() int {
+	queue, err := GetReportQueue(user)
+	if err != nil {
+		fmt.Printf("[error] Could not count reported posts (%s)\n", err.Error())
+		return 0
+	}
+
+	return len(queue)
+}
+
+// Closes every open report about the post, recording what the moderator
+// did, and lets each reporter know
+func ResolvePostReports(post *Post, moderator *User, status string) error {
+	db := GetDbSession()
+
+	var reports []*PostReport
+	_, err := db.Select(&reports, "UPDATE post_reports SET status=$1, handled_by=$2, handled_on=$3 WHERE post_id=$4 AND status=$5 RETURNING *", status, moderator.ID, time.Now(), post.ID, ReportOpen)
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		err := db.Insert(&Notification{
+			UserID:    report.ReporterID,
+			ActorID:   moderator.ID,
+			PostID:    post.ID,
+			Type:      NotificationReport,
+			ReportID:  nullID(report.ID),
+			CreatedOn: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (report *PostReport) GetReporter() *User {
+	user, _ := GetUser(int(report.ReporterID))
+	return user
+}
+
+func (report *PostReport) GetHandledBy() *User {
+	if !report.HandledBy.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(report.HandledBy.Int64))
+	return user
+}
+
+// Explains to the reporter what was done about their report
+func (report *PostReport) DescribeOutcome() string {
+	switch report.Status {
+	case ReportDeleted:
+		return "the post was removed"
+	case ReportLocked:
+		return "the thread was locked"
+	case ReportBanned:
+		return "the author was banned"
+	case ReportDismissed:
+		return "no action was needed"
+	}
+
+	return "it's waiting for a moderator"
+}
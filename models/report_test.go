@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReportPostCantReopenHandledReports(t *testing.T) {
+	db := requireTestDB(t)
+	author, board := newTestUserAndBoard(t, db)
+	post := newTestPost(t, db, author, board, nil)
+
+	reporter, err := NewUser(fmt.Sprintf("reporter%d", time.Now().UnixNano()), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Insert(reporter); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM notifications WHERE post_id=$1", post.ID)
+		db.Exec("DELETE FROM post_reports WHERE post_id=$1", post.ID)
+		db.Delete(reporter)
+	})
+
+	getReport := func() *PostReport {
+		report := &PostReport{}
+		if err := db.SelectOne(report, "SELECT * FROM post_reports WHERE post_id=$1 AND reporter_id=$2", post.ID, reporter.ID); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	if err := ReportPost(reporter, post, "spam", "First"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reporting again while it's open just updates the report
+	if err := ReportPost(reporter, post, "other", "Second"); err != nil {
+		t.Fatal(err)
+	}
+	if report := getReport(); report.Reason != "Second" || report.Status != ReportOpen {
+		t.Errorf("Open report wasn't updated: %+v", report)
+	}
+
+	if err := ResolvePostReports(post, author, ReportDismissed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReportPost(reporter, post, "spam", "Third"); err == nil {
+		t.Error("A dismissed report could be reported again")
+	}
+	if report := getReport(); report.Status != ReportDismissed || report.Reason != "Second" {
+		t.Errorf("Dismissed report was reopened: %+v", report)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/coopernurse/gorp"
+)
+
+// Matches in search snippets are wrapped in these so they can be
+// highlighted once the rest of the snippet has been escaped
+const (
+	SearchMatchStart = "\x01"
+	SearchMatchStop  = "\x02"
+)
+
+const searchResultsPerPage = 20
+
+// What to search for. Zero values leave a filter off.
+type SearchQuery struct {
+	Terms       string
+	BoardID     int64
+	AuthorID    int64
+	From        time.Time
+	To          time.Time
+	ThreadsOnly bool
+}
+
+type SearchResult struct {
+	PostID  int64   `db:"id"`
+	Snippet string  `db:"snippet"`
+	Rank    float64 `db:"rank"`
+	Post    *Post   `db:"-"`
+	Thread  *Post   `db:"-"`
+	Board   *Board  `db:"-"`
+}
+
+// Refreshes the post's entry in the search index. Titles weigh more than
+// the body.
+func (post *Post) UpdateSearchIndex() error {
+	return updateSearchIndex(GetDbSession(), post)
+}
+
+func updateSearchIndex(db gorp.SqlExecutor, post *Post) error {
+	_, err := db.Exec(`
+        INSERT INTO post_search (post_id, search_vector)
+        VALUES ($1, setweight(to_tsvector('english', $2), 'A') || setweight(to_tsvector('english', $3), 'B'))
+        ON CONFLICT (post_id) DO UPDATE SET search_vector=EXCLUDED.search_vector
+    `, post.ID, post.Title, post.Content)
+
+	return err
+}
+
+func (query *SearchQuery) where(viewer *User) (string, []interface{}) {
+	args := []interface{}{query.Terms}
+	clauses := []string{
+		"post_search.search_vector @@ websearch_to_tsquery('english', $1)",
+		"posts.deleted_at IS NULL",
+		"NOT posts.pending",
+		postReadableSQL(viewer),
+	}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+
+	if query.BoardID != 0 {
+		add("posts.board_id=$%d", query.BoardID)
+	}
+	if query.AuthorID != 0 {
+		add("posts.author_id=$%d", query.AuthorID)
+	}
+	if !query.From.IsZero() {
+		add("posts.created_on >= $%d", query.From)
+	}
+	if !query.To.IsZero() {
+		add("posts.created_on < $%d", query.To)
+	}
+	if query.ThreadsOnly {
+		clauses = append(clauses, "posts.parent_id IS NULL")
+	}
+
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// Returns a page of posts matching the query which the viewer is allowed
+// to see, best matches first, along with the total number of pages
+func Search(query *SearchQuery, viewer *User, page int) ([]*SearchResult, int, error) {
+	if strings.TrimSpace(query.Terms) == "" {
+		return nil, 0, nil
+	}
+
+	db := GetDbSession()
+	where, args := query.where(viewer)
+
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts JOIN post_search ON post_search.post_id=posts.id"+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	pages := int(math.Ceil(float64(count) / searchResultsPerPage))
+
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", SearchMatchStart, SearchMatchStop)
+	args = append(args, options)
+
+	var results []*SearchResult
+	_, err = db.Select(&results, fmt.Sprintf(`
+        SELECT
+            posts.id,
+            ts_headline('english', posts.content, websearch_to_tsquery('english', $1), $%d) AS snippet,
+            ts_rank(post_search.search_vector, websearch_to_tsquery('english', $1)) AS rank
+        FROM posts
+        JOIN post_search ON post_search.post_id=posts.id
+        %s
+        ORDER BY rank DESC, posts.created_on DESC
+        LIMIT %d OFFSET %d
+    `, len(args), where, searchResultsPerPage, page*searchResultsPerPage), args...)
+	if err != nil {
+		return nil, pages, err
+	}
+
+	boards := map[int64]*Board{}
+	for _, result := range results {
+		result.Post, _ = GetPost(int(result.PostID))
+		if result.Post == nil {
+			continue
+		}
+
+		result.Thread = result.Post
+		if result.Post.ParentID.Valid {
+			result.Thread, _ = GetPost(int(result.Post.ParentID.Int64))
+		}
+
+		board, ok := boards[result.Post.BoardID]
+		if !ok {
+			board, _ = GetBoard(int(result.Post.BoardID))
+			boards[result.Post.BoardID] = board
+		}
+		result.Board = board
+	}
+
+	return results, pages, nil
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/stevenleeg/gobb/config"
+)
+
+// A Session is a single login from a single browser. The cookie only ever
+// holds the random token; we keep a hash of it so a leaked database can't
+// be used to hijack anyone's login.
+type Session struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	Token     string    `db:"token"`
+	CreatedOn time.Time `db:"created_on"`
+	LastUsed  time.Time `db:"last_used"`
+	ExpiresOn time.Time `db:"expires_on"`
+	UserAgent string    `db:"user_agent"`
+	IP        string    `db:"ip"`
+	CSRFToken string    `db:"csrf_token"`
+}
+
+// How long a session stays valid after it was last used. Configured in
+// days with the session_lifetime option, defaulting to 30.
+func getSessionLifetime() time.Duration {
+	days, err := config.Config.GetInt64("gobb", "session_lifetime")
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Creates and stores a new session for the given user. The plaintext token
+// is returned separately and is never stored.
+func NewSession(user *User, userAgent, ip string) (*Session, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := base64.URLEncoding.EncodeToString(raw)
+
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return nil, "", err
+	}
+
+	session := &Session{
+		UserID:    user.ID,
+		Token:     hashSessionToken(token),
+		CreatedOn: time.Now(),
+		LastUsed:  time.Now(),
+		ExpiresOn: time.Now().Add(getSessionLifetime()),
+		UserAgent: TruncateText(userAgent, 255),
+		IP:        ip,
+		CSRFToken: hex.EncodeToString(csrf),
+	}
+
+	db := GetDbSession()
+	err := db.Insert(session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, token, nil
+}
+
+// Looks up an unexpired session by the token stored in a user's cookie
+func GetSessionByToken(token string) (*Session, error) {
+	if token == "" {
+		return nil, errors.New("Empty session token")
+	}
+
+	db := GetDbSession()
+	session := &Session{}
+	err := db.SelectOne(session, "SELECT * FROM sessions WHERE token=$1 AND expires_on > $2", hashSessionToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Records that the session was just used and pushes back its expiry.
+// Writes are skipped if the session was touched within the last minute.
+func (session *Session) Touch(ip string) {
+	if time.Since(session.LastUsed) < time.Minute && session.IP == ip {
+		return
+	}
+
+	session.LastUsed = time.Now()
+	session.ExpiresOn = time.Now().Add(getSessionLifetime())
+	session.IP = ip
+
+	db := GetDbSession()
+	db.Update(session)
+}
+
+// Ends the session. The token stops working immediately.
+func (session *Session) Revoke() error {
+	db := GetDbSession()
+	_, err := db.Delete(session)
+	return err
+}
+
+// Revokes every one of the user's sessions except for the one with the
+// given ID. Pass 0 to sign the user out everywhere.
+func (user *User) RevokeOtherSessions(keepID int64) error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM sessions WHERE user_id=$1 AND id!=$2", user.ID, keepID)
+	return err
+}
+
+// Removes sessions which have expired
+func DeleteExpiredSessions() error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM sessions WHERE expires_on <= $1", time.Now())
+	return err
+}
+
+func GetSession(ID int) (*Session, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Session{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Session), err
+}
+
+// Returns all of the user's unexpired sessions, most recently used first
+func (user *User) GetSessions() ([]*Session, error) {
+	db := GetDbSession()
+
+	var sessions []*Session
+	_, err := db.Select(&sessions, "SELECT * FROM sessions WHERE user_id=$1 AND expires_on > $2 ORDER BY last_used DESC", user.ID, time.Now())
+
+	return sessions, err
+}
@@ -0,0 +1,452 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/coopernurse/gorp"
+	"github.com/stevenleeg/gobb/config"
+)
+
+// How many posts marked as spam and not spam a feature turned up in
+type SpamToken struct {
+	Token     string `db:"token"`
+	SpamCount int64  `db:"spam_count"`
+	HamCount  int64  `db:"ham_count"`
+}
+
+// A moderator's decision on whether a post is spam. Features holds what
+// the filter was taught from it, separated by spaces.
+type SpamVerdict struct {
+	PostID   int64         `db:"post_id"`
+	Spam     bool          `db:"spam"`
+	Held     bool          `db:"held"`
+	Features string        `db:"features"`
+	MarkedBy sql.NullInt64 `db:"marked_by"`
+	MarkedOn time.Time     `db:"marked_on"`
+}
+
+// How well the filter has done, going by what moderators made of the posts
+// it saw
+type SpamStats struct {
+	Enabled     bool
+	Threshold   float64
+	MinTraining int64
+
+	SpamPosts int64
+	HamPosts  int64
+	Tokens    int64
+
+	// Posts the filter held which turned out to be spam, and which didn't
+	HeldSpam int64
+	HeldHam  int64
+	// Spam the filter let through
+	MissedSpam int64
+	// Held posts nobody has looked at yet
+	Waiting int64
+}
+
+const (
+	// Only the features the filter is surest about decide a post's score,
+	// so a long post can't drown out the parts that give it away
+	spamScoredFeatures = 20
+	// Longest word that's worth learning
+	spamMaxWordLength = 30
+	// Matches the size of spam_tokens.token
+	spamMaxFeatureLength = 64
+)
+
+var spamLinkRegexp = regexp.MustCompile(`(?i)\bhttps?://([^/\s()<>\[\]"']+)`)
+
+// The filter is on unless the [spam] section of the config turns it off
+func spamFilterEnabled() bool {
+	enabled, err := config.Config.GetBool("spam", "enabled")
+	return err != nil || enabled
+}
+
+// The score from 0 to 1 at which posts are held for review
+func getSpamThreshold() float64 {
+	threshold, err := config.Config.GetFloat("spam", "threshold")
+	if err != nil || threshold <= 0 {
+		return 0.9
+	}
+
+	return threshold
+}
+
+// How many posts have to be marked both as spam and as not spam before
+// the filter holds anything
+func getSpamMinTraining() int64 {
+	count, err := config.Config.GetInt64("spam", "min_training")
+	if err != nil || count < 1 {
+		return 10
+	}
+
+	return count
+}
+
+// Groups a count so that similar counts make the same feature
+func spamBucket(count int64) string {
+	switch {
+	case count <= 1:
+		return fmt.Sprintf("%d", count)
+	case count <= 3:
+		return "2-3"
+	case count <= 9:
+		return "4-9"
+	}
+
+	return "10+"
+}
+
+// Counts the posts the author made in the hour before the given time,
+// other than the post itself. Posts moderators deleted or rejected don't
+// count. Posts waiting for approval do, since a burst of held posts is
+// just what this is meant to catch.
+func countRecentPosts(author *User, at time.Time, postID int64) int64 {
+	db := GetDbSession()
+	recent, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE author_id=$1 AND id<>$2 AND deleted_at IS NULL AND created_on > $3 AND created_on <= $4", author.ID, postID, at.Add(-time.Hour), at)
+	if err != nil {
+		fmt.Printf("[error] Could not count recent posts (%s)\n", err.Error())
+	}
+
+	return recent
+}
+
+// Breaks text down into what the filter looks at: its words, the sites it
+// links to and how many links there are, how old the author's account is
+// and how many other posts they made in the hour before (recent, from
+// countRecentPosts). Each feature is listed once. Words never contain a
+// colon, so the rest can't be mistaken for them.
+func getSpamFeatures(text string, author *User, at time.Time, recent int64) []string {
+	seen := map[string]bool{}
+	var features []string
+	add := func(feature string) {
+		if utf8.RuneCountInString(feature) > spamMaxFeatureLength {
+			feature = string([]rune(feature)[:spamMaxFeatureLength])
+		}
+		if !seen[feature] {
+			seen[feature] = true
+			features = append(features, feature)
+		}
+	}
+
+	links := spamLinkRegexp.FindAllStringSubmatch(text, -1)
+	for _, link := range links {
+		add("site:" + strings.ToLower(link[1]))
+	}
+	add("links:" + spamBucket(int64(len(links))))
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if length := utf8.RuneCountInString(word); length >= 3 && length <= spamMaxWordLength {
+			add(word)
+		}
+	}
+
+	age := at.Sub(author.CreatedOn)
+	switch {
+	case age < 24*time.Hour:
+		add("age:day")
+	case age < 7*24*time.Hour:
+		add("age:week")
+	case age < 30*24*time.Hour:
+		add("age:month")
+	default:
+		add("age:older")
+	}
+
+	add("velocity:" + spamBucket(recent))
+
+	return features
+}
+
+func (post *Post) getSpamFeatures(author *User) []string {
+	recent := countRecentPosts(author, post.CreatedOn, post.ID)
+	return getSpamFeatures(post.Title+"\n"+post.Content, author, post.CreatedOn, recent)
+}
+
+// Scores features from 0 (not spam) to 1 (spam) with naive Bayes. ok is
+// false until the filter has been taught enough to be trusted.
+func scoreSpamFeatures(features []string) (score float64, ok bool, err error) {
+	db := GetDbSession()
+
+	spamPosts, err := db.SelectInt("SELECT COUNT(*) FROM spam_verdicts WHERE spam")
+	if err != nil {
+		return 0, false, err
+	}
+	hamPosts, err := db.SelectInt("SELECT COUNT(*) FROM spam_verdicts WHERE NOT spam")
+	if err != nil {
+		return 0, false, err
+	}
+
+	minTraining := getSpamMinTraining()
+	if spamPosts < minTraining || hamPosts < minTraining || len(features) == 0 {
+		return 0, false, nil
+	}
+
+	placeholders := make([]string, len(features))
+	args := make([]interface{}, len(features))
+	for i, feature := range features {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = feature
+	}
+
+	var tokens []*SpamToken
+	_, err = db.Select(&tokens, "SELECT * FROM spam_tokens WHERE token IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return spamScore(tokens, spamPosts, hamPosts), true, nil
+}
+
+// Works out the naive Bayes score of a post from what the filter knows of
+// its features, given how many posts it's been taught are spam and not
+func spamScore(tokens []*SpamToken, spamPosts, hamPosts int64) float64 {
+	// How much more likely each known feature is to turn up in spam than
+	// not, smoothed so that one sighting doesn't settle anything
+	var evidence []float64
+	for _, token := range tokens {
+		pSpam := float64(token.SpamCount+1) / float64(spamPosts+2)
+		pHam := float64(token.HamCount+1) / float64(hamPosts+2)
+		evidence = append(evidence, math.Log(pSpam/pHam))
+	}
+
+	sort.Slice(evidence, func(i, j int) bool {
+		return math.Abs(evidence[i]) > math.Abs(evidence[j])
+	})
+	if len(evidence) > spamScoredFeatures {
+		evidence = evidence[:spamScoredFeatures]
+	}
+
+	logOdds := math.Log(float64(spamPosts) / float64(hamPosts))
+	for _, weight := range evidence {
+		logOdds += weight
+	}
+
+	return 1 / (1 + math.Exp(-logOdds))
+}
+
+// Scores a new post and holds it for review if it looks like spam. People
+// who can approve posts are trusted, and a broken filter never stops
+// anyone posting.
+func (post *Post) checkSpam(author *User, board *Board) {
+	if !spamFilterEnabled() || Can(author, PermPostApprove, board) {
+		return
+	}
+
+	// Kept so that a moderator's verdict later teaches the filter from
+	// what it saw now, not from the post as it is by then
+	features := post.getSpamFeatures(author)
+	post.SpamFeatures = strings.Join(features, " ")
+
+	score, ok, err := scoreSpamFeatures(features)
+	if err != nil {
+		fmt.Printf("[error] Could not check post for spam (%s)\n", err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+
+	post.SpamScore = sql.NullFloat64{Float64: score, Valid: true}
+	if score >= getSpamThreshold() {
+		post.SpamHeld = true
+		post.Pending = true
+	}
+}
+
+// Whether the filter thinks the user's signature is spam. Signatures can't
+// wait for approval, so they're turned away instead.
+func (user *User) IsSpamSignature(signature string) bool {
+	if !spamFilterEnabled() || Can(user, PermPostApprove, nil) {
+		return false
+	}
+
+	now := time.Now()
+	score, ok, err := scoreSpamFeatures(getSpamFeatures(signature, user, now, countRecentPosts(user, now, 0)))
+	if err != nil {
+		fmt.Printf("[error] Could not check signature for spam (%s)\n", err.Error())
+		return false
+	}
+
+	return ok && score >= getSpamThreshold()
+}
+
+// The filter's score for the post as a percentage
+func (post *Post) GetSpamPercent() int {
+	return int(math.Floor(post.SpamScore.Float64*100 + 0.5))
+}
+
+// Adds delta to the spam or not spam count of every feature
+func trainSpamFeatures(tx *gorp.Transaction, features []string, spam bool, delta int) error {
+	column := "ham_count"
+	if spam {
+		column = "spam_count"
+	}
+
+	for _, feature := range features {
+		_, err := tx.Exec(`
+            INSERT INTO spam_tokens (token, `+column+`) VALUES ($1, GREATEST($2, 0))
+            ON CONFLICT (token) DO UPDATE SET `+column+`=GREATEST(spam_tokens.`+column+` + $2, 0)
+        `, feature, delta)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetSpamVerdict(post *Post) *SpamVerdict {
+	db := GetDbSession()
+	obj, err := db.Get(&SpamVerdict{}, post.ID)
+	if err != nil {
+		fmt.Printf("[error] Could not get spam verdict (%s)\n", err.Error())
+	}
+	if obj == nil {
+		return nil
+	}
+
+	return obj.(*SpamVerdict)
+}
+
+// Records a moderator's decision on whether a post is spam and teaches the
+// filter from the features it scored the post on. Changing the decision
+// takes back what the old one taught.
+func MarkSpam(post *Post, moderator *User, spam bool) error {
+	author, _ := GetUser(int(post.AuthorID))
+	if author == nil {
+		return errors.New("Could not find post's author")
+	}
+
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var existing []*SpamVerdict
+	_, err = tx.Select(&existing, "SELECT * FROM spam_verdicts WHERE post_id=$1 FOR UPDATE", post.ID)
+
+	verdict := &SpamVerdict{}
+	found := len(existing) > 0
+	if found {
+		verdict = existing[0]
+		if verdict.Spam == spam {
+			tx.Rollback()
+			return nil
+		}
+
+		err = trainSpamFeatures(tx, strings.Fields(verdict.Features), verdict.Spam, -1)
+	}
+
+	// Posts the filter never scored, such as those made before it was
+	// turned on, are taught from what they look like now
+	features := strings.Fields(post.SpamFeatures)
+	if len(features) == 0 {
+		features = post.getSpamFeatures(author)
+	}
+	if err == nil {
+		err = trainSpamFeatures(tx, features, spam, 1)
+	}
+
+	if err == nil {
+		verdict.PostID = post.ID
+		verdict.Spam = spam
+		verdict.Held = post.SpamHeld
+		verdict.Features = strings.Join(features, " ")
+		verdict.MarkedBy = sql.NullInt64{Int64: moderator.ID, Valid: true}
+		verdict.MarkedOn = time.Now()
+
+		if found {
+			_, err = tx.Update(verdict)
+		} else {
+			err = tx.Insert(verdict)
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func GetSpamStats() (*SpamStats, error) {
+	db := GetDbSession()
+	stats := &SpamStats{
+		Enabled:     spamFilterEnabled(),
+		Threshold:   getSpamThreshold(),
+		MinTraining: getSpamMinTraining(),
+	}
+
+	counts := []struct {
+		count *int64
+		query string
+	}{
+		{&stats.SpamPosts, "SELECT COUNT(*) FROM spam_verdicts WHERE spam"},
+		{&stats.HamPosts, "SELECT COUNT(*) FROM spam_verdicts WHERE NOT spam"},
+		{&stats.Tokens, "SELECT COUNT(*) FROM spam_tokens"},
+		{&stats.HeldSpam, "SELECT COUNT(*) FROM spam_verdicts WHERE held AND spam"},
+		{&stats.HeldHam, "SELECT COUNT(*) FROM spam_verdicts WHERE held AND NOT spam"},
+		{&stats.MissedSpam, "SELECT COUNT(*) FROM spam_verdicts WHERE NOT held AND spam"},
+		{&stats.Waiting, "SELECT COUNT(*) FROM posts WHERE spam_held AND pending AND deleted_at IS NULL"},
+	}
+
+	for _, c := range counts {
+		count, err := db.SelectInt(c.query)
+		if err != nil {
+			return nil, err
+		}
+		*c.count = count
+	}
+
+	return stats, nil
+}
+
+// Whether the filter has been taught enough to hold posts
+func (stats *SpamStats) IsTrained() bool {
+	return stats.SpamPosts >= stats.MinTraining && stats.HamPosts >= stats.MinTraining
+}
+
+func formatSpamRatio(part, whole int64) string {
+	if whole == 0 {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(whole))
+}
+
+// How many of the posts the filter held were really spam
+func (stats *SpamStats) GetPrecision() string {
+	return formatSpamRatio(stats.HeldSpam, stats.HeldSpam+stats.HeldHam)
+}
+
+// How much of the spam moderators found the filter held
+func (stats *SpamStats) GetRecall() string {
+	return formatSpamRatio(stats.HeldSpam, stats.HeldSpam+stats.MissedSpam)
+}
+
+// The features which most give spam away, for admins curious about what
+// the filter has learnt
+func GetTopSpamTokens(limit int) ([]*SpamToken, error) {
+	db := GetDbSession()
+
+	var tokens []*SpamToken
+	_, err := db.Select(&tokens, "SELECT * FROM spam_tokens WHERE spam_count > ham_count ORDER BY spam_count - ham_count DESC, token ASC LIMIT $1", limit)
+
+	return tokens, err
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpamBucket(t *testing.T) {
+	tests := map[int64]string{
+		0:   "0",
+		1:   "1",
+		2:   "2-3",
+		3:   "2-3",
+		4:   "4-9",
+		9:   "4-9",
+		10:  "10+",
+		500: "10+",
+	}
+
+	for count, want := range tests {
+		if got := spamBucket(count); got != want {
+			t.Errorf("spamBucket(%d) = %q, want %q", count, got, want)
+		}
+	}
+}
+
+func TestGetSpamFeatures(t *testing.T) {
+	now := time.Now()
+	author := &User{ID: 1, CreatedOn: now.Add(-2 * time.Hour)}
+	text := "Cheap PILLS, cheap pills at https://Pills.example/buy and http://pills.example/more " +
+		"ok " + strings.Repeat("x", spamMaxWordLength+1)
+
+	features := getSpamFeatures(text, author, now, 5)
+
+	has := map[string]bool{}
+	for _, feature := range features {
+		if has[feature] {
+			t.Errorf("Feature %q was listed twice", feature)
+		}
+		has[feature] = true
+	}
+
+	for _, want := range []string{"cheap", "pills", "site:pills.example", "links:2-3", "age:day", "velocity:4-9"} {
+		if !has[want] {
+			t.Errorf("Missing feature %q in %v", want, features)
+		}
+	}
+	for _, unwanted := range []string{"ok", strings.Repeat("x", spamMaxWordLength+1)} {
+		if has[unwanted] {
+			t.Errorf("Word %q should have been skipped", unwanted)
+		}
+	}
+
+	old := &User{ID: 2, CreatedOn: now.Add(-365 * 24 * time.Hour)}
+	features = getSpamFeatures("Nothing to see", old, now, 0)
+	for _, want := range []string{"links:0", "age:older", "velocity:0"} {
+		if !strings.Contains(" "+strings.Join(features, " ")+" ", " "+want+" ") {
+			t.Errorf("Missing feature %q in %v", want, features)
+		}
+	}
+}
+
+func TestSpamScore(t *testing.T) {
+	// A model taught on 20 spam and 20 other posts
+	model := map[string]*SpamToken{
+		"pills":              {Token: "pills", SpamCount: 18, HamCount: 0},
+		"site:pills.example": {Token: "site:pills.example", SpamCount: 15, HamCount: 0},
+		"age:day":            {Token: "age:day", SpamCount: 16, HamCount: 3},
+		"thread":             {Token: "thread", SpamCount: 1, HamCount: 12},
+		"thanks":             {Token: "thanks", SpamCount: 0, HamCount: 14},
+		"age:older":          {Token: "age:older", SpamCount: 2, HamCount: 15},
+	}
+	score := func(features ...string) float64 {
+		var tokens []*SpamToken
+		for _, feature := range features {
+			if token, ok := model[feature]; ok {
+				tokens = append(tokens, token)
+			}
+		}
+		return spamScore(tokens, 20, 20)
+	}
+
+	if got := score("pills", "site:pills.example", "age:day"); got < 0.99 {
+		t.Errorf("Spammy post scored %.3f", got)
+	}
+	if got := score("thanks", "thread", "age:older"); got > 0.01 {
+		t.Errorf("Ordinary post scored %.3f", got)
+	}
+	if got := score("never", "seen", "before"); got != 0.5 {
+		t.Errorf("Post with no known features scored %.3f, want the prior of 0.5", got)
+	}
+
+	// Only the strongest features count, so piling on weak ones can't
+	// hide the ones that give a post away
+	var tokens []*SpamToken
+	for i := 0; i < spamScoredFeatures*3; i++ {
+		tokens = append(tokens, &SpamToken{Token: fmt.Sprintf("weak%d", i), SpamCount: 9, HamCount: 10})
+	}
+	tokens = append(tokens, model["pills"], model["site:pills.example"])
+	if got := spamScore(tokens, 20, 20); got < 0.9 {
+		t.Errorf("Weak features drowned out strong ones: scored %.3f", got)
+	}
+}
+
+// Reads how many spam and other posts a feature has been taught from
+func getSpamTokenCounts(t *testing.T, token string) (int64, int64) {
+	db := GetDbSession()
+	obj, err := db.Get(&SpamToken{}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj == nil {
+		return 0, 0
+	}
+
+	return obj.(*SpamToken).SpamCount, obj.(*SpamToken).HamCount
+}
+
+func TestMarkSpamRetrainsOnChangedVerdict(t *testing.T) {
+	db := requireTestDB(t)
+	user, board := newTestUserAndBoard(t, db)
+
+	post := newTestPost(t, db, user, board, nil)
+	feature := fmt.Sprintf("testfeature%d", post.ID)
+	post.SpamFeatures = feature
+	if _, err := db.Update(post); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM spam_verdicts WHERE post_id=$1", post.ID)
+		db.Exec("DELETE FROM spam_tokens WHERE token=$1", feature)
+	})
+
+	if err := MarkSpam(post, user, true); err != nil {
+		t.Fatal(err)
+	}
+	if spam, ham := getSpamTokenCounts(t, feature); spam != 1 || ham != 0 {
+		t.Errorf("After marking as spam, counts are %d spam and %d not, want 1 and 0", spam, ham)
+	}
+
+	// Changing the verdict takes back what the first one taught
+	if err := MarkSpam(post, user, false); err != nil {
+		t.Fatal(err)
+	}
+	if spam, ham := getSpamTokenCounts(t, feature); spam != 0 || ham != 1 {
+		t.Errorf("After changing the verdict, counts are %d spam and %d not, want 0 and 1", spam, ham)
+	}
+
+	// The same verdict again teaches nothing new
+	if err := MarkSpam(post, user, false); err != nil {
+		t.Fatal(err)
+	}
+	if spam, ham := getSpamTokenCounts(t, feature); spam != 0 || ham != 1 {
+		t.Errorf("After repeating the verdict, counts are %d spam and %d not, want 0 and 1", spam, ham)
+	}
+
+	if verdict := GetSpamVerdict(post); verdict == nil || verdict.Spam || verdict.Features != feature {
+		t.Errorf("Verdict wasn't saved with the scored features: %+v", verdict)
+	}
+}
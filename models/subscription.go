@@ -0,0 +1,169 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A user watching a thread. Watchers are notified of replies and see the
+// thread on their subscriptions page.
+type ThreadSubscription struct {
+	ThreadID  int64     `db:"thread_id"`
+	UserID    int64     `db:"user_id"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// A user watching a board for new activity
+type BoardSubscription struct {
+	BoardID   int64     `db:"board_id"`
+	UserID    int64     `db:"user_id"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// A watched thread along with how much of it the user hasn't read yet
+type WatchedThread struct {
+	Thread      *Post       `db:"-"`
+	ID          int64       `db:"id"`
+	BoardID     int64       `db:"board_id"`
+	Title       string      `db:"title"`
+	LatestReply time.Time   `db:"latest_reply"`
+	ViewedOn    pq.NullTime `db:"viewed_on"`
+	Unread      int64       `db:"unread"`
+}
+
+// A watched board along with how many of its threads have activity the
+// user hasn't seen
+type WatchedBoard struct {
+	ID          int64  `db:"id"`
+	Title       string `db:"title"`
+	Description string `db:"description"`
+	Unread      int64  `db:"unread"`
+}
+
+func (user *User) IsWatchingThread(thread *Post) bool {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM thread_subscriptions WHERE thread_id=$1 AND user_id=$2", thread.ID, user.ID)
+	return err == nil && count > 0
+}
+
+func (user *User) WatchThread(thread *Post) error {
+	if user.IsWatchingThread(thread) {
+		return nil
+	}
+
+	db := GetDbSession()
+	return db.Insert(&ThreadSubscription{
+		ThreadID:  thread.ID,
+		UserID:    user.ID,
+		CreatedOn: time.Now(),
+	})
+}
+
+func (user *User) UnwatchThread(thread *Post) error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM thread_subscriptions WHERE thread_id=$1 AND user_id=$2", thread.ID, user.ID)
+	return err
+}
+
+func (user *User) IsWatchingBoard(board *Board) bool {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM board_subscriptions WHERE board_id=$1 AND user_id=$2", board.ID, user.ID)
+	return err == nil && count > 0
+}
+
+func (user *User) WatchBoard(board *Board) error {
+	if user.IsWatchingBoard(board) {
+		return nil
+	}
+
+	db := GetDbSession()
+	return db.Insert(&BoardSubscription{
+		BoardID:   board.ID,
+		UserID:    user.ID,
+		CreatedOn: time.Now(),
+	})
+}
+
+func (user *User) UnwatchBoard(board *Board) error {
+	db := GetDbSession()
+	_, err := db.Exec("DELETE FROM board_subscriptions WHERE board_id=$1 AND user_id=$2", board.ID, user.ID)
+	return err
+}
+
+// Returns the threads the user is watching, most recently active first.
+// Unread counts only include replies by other people made since the user
+// last viewed the thread or marked everything read.
+func (user *User) GetWatchedThreads() ([]*WatchedThread, error) {
+	db := GetDbSession()
+
+	var threads []*WatchedThread
+	_, err := db.Select(&threads, `
+        SELECT
+            posts.id,
+            posts.board_id,
+            posts.title,
+            posts.latest_reply,
+            views.time AS viewed_on,
+            (SELECT COUNT(*) FROM posts replies WHERE
+                replies.parent_id=posts.id AND
+                replies.deleted_at IS NULL AND
+                NOT replies.pending AND
+                replies.author_id!=$1 AND
+                replies.created_on > COALESCE(GREATEST(views.time, $2::timestamp), '-infinity')
+            ) AS unread
+        FROM thread_subscriptions
+        INNER JOIN posts ON posts.id=thread_subscriptions.thread_id
+        LEFT OUTER JOIN views ON
+            views.post_id=posts.id AND
+            views.user_id=$1
+        WHERE
+            thread_subscriptions.user_id=$1 AND
+            posts.deleted_at IS NULL AND
+            NOT posts.pending AND
+            `+postReadableSQL(user)+`
+        ORDER BY posts.latest_reply DESC
+    `, user.ID, user.LastUnreadAll)
+
+	for i := range threads {
+		threads[i].Thread = &Post{
+			ID:      threads[i].ID,
+			BoardID: threads[i].BoardID,
+		}
+	}
+
+	return threads, err
+}
+
+// Returns the boards the user is watching in board order. Threads are only
+// counted as unread if they've been active since the user started watching.
+func (user *User) GetWatchedBoards() ([]*WatchedBoard, error) {
+	db := GetDbSession()
+
+	var boards []*WatchedBoard
+	_, err := db.Select(&boards, `
+        SELECT
+            boards.id,
+            boards.title,
+            boards.description,
+            (SELECT COUNT(*) FROM posts
+                LEFT OUTER JOIN views ON
+                    views.post_id=posts.id AND
+                    views.user_id=$1
+                WHERE
+                    posts.board_id=boards.id AND
+                    posts.parent_id IS NULL AND
+                    posts.deleted_at IS NULL AND
+                    NOT posts.pending AND
+                    posts.latest_reply > GREATEST(views.time, $2::timestamp, board_subscriptions.created_on)
+            ) AS unread
+        FROM board_subscriptions
+        INNER JOIN boards ON boards.id=board_subscriptions.board_id
+        WHERE
+            board_subscriptions.user_id=$1 AND
+            `+boardReadableSQL(user)+`
+        ORDER BY boards.ordering ASC
+    `, user.ID, user.LastUnreadAll)
+
+	return boards, err
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Shortens text to at most length characters without splitting one.
+// Invalid UTF-8 and NUL bytes are dropped first, since Postgres refuses to
+// store either.
+func TruncateText(text string, length int) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.Replace(text, "\x00", "", -1)
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	return string([]rune(text)[:length])
+}
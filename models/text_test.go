@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		in     string
+		length int
+		want   string
+	}{
+		{"short", 255, "short"},
+		{"abcdef", 3, "abc"},
+		{"héllo wörld", 7, "héllo w"},
+		{"日本語のテキスト", 3, "日本語"},
+		{"bad \xff\xfe bytes", 255, "bad  bytes"},
+		{"nul\x00byte", 255, "nulbyte"},
+		{"", 10, ""},
+	}
+
+	for _, test := range tests {
+		got := TruncateText(test.in, test.length)
+		if got != test.want {
+			t.Errorf("TruncateText(%q, %d) = %q, want %q", test.in, test.length, got, test.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("TruncateText(%q, %d) returned invalid UTF-8", test.in, test.length)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stevenleeg/gobb/config"
+)
+
+// Deleting posts and boards only moves them to the trash. Moderators can
+// put them back from there, and they're only removed for good when an
+// admin purges them or they've been in the trash for longer than the
+// trash_retention setting.
+
+func (post *Post) IsDeleted() bool {
+	return post.DeletedAt.Valid
+}
+
+func (board *Board) IsDeleted() bool {
+	return board.DeletedAt.Valid
+}
+
+func (post *Post) GetDeletedBy() *User {
+	if !post.DeletedBy.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(post.DeletedBy.Int64))
+	return user
+}
+
+func (board *Board) GetDeletedBy() *User {
+	if !board.DeletedBy.Valid {
+		return nil
+	}
+
+	user, _ := GetUser(int(board.DeletedBy.Int64))
+	return user
+}
+
+// Moves the post to the trash. Deleting a thread takes its replies with
+// it; they share the thread's deletion time so that restoring the thread
+// brings back exactly the replies it took.
+func (post *Post) SoftDelete(moderator *User, reason string) error {
+	if post.IsDeleted() {
+		return errors.New("This post has already been deleted")
+	}
+
+	now := time.Now()
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE posts SET deleted_at=$1, deleted_by=$2, delete_reason=$3 WHERE id=$4 OR (parent_id=$4 AND deleted_at IS NULL)", now, moderator.ID, reason, post.ID)
+	if err != nil {
+		return err
+	}
+
+	post.DeletedAt = pq.NullTime{Time: now, Valid: true}
+	post.DeletedBy = sql.NullInt64{Int64: moderator.ID, Valid: true}
+	post.DeleteReason = reason
+	return nil
+}
+
+// Takes the post out of the trash, along with any replies which were
+// deleted with it
+func (post *Post) Restore() error {
+	if !post.IsDeleted() {
+		return nil
+	}
+
+	db := GetDbSession()
+	if post.ParentID.Valid {
+		op, _ := GetPost(int(post.ParentID.Int64))
+		if op != nil && op.IsDeleted() {
+			return errors.New("The thread this post belongs to is in the trash. Restore the thread instead.")
+		}
+	}
+
+	_, err := db.Exec("UPDATE posts SET deleted_at=NULL, deleted_by=NULL, delete_reason='' WHERE id=$1 OR (parent_id=$1 AND deleted_at=$2)", post.ID, post.DeletedAt.Time)
+	if err != nil {
+		return err
+	}
+
+	post.DeletedAt = pq.NullTime{}
+	post.DeletedBy = sql.NullInt64{}
+	post.DeleteReason = ""
+	return nil
+}
+
+// Removes the post for good. Purging a thread removes all of its replies.
+func (post *Post) Purge() error {
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		"DELETE FROM views WHERE post_id=$1 OR post_id IN (SELECT id FROM posts WHERE parent_id=$1)",
+		"DELETE FROM posts WHERE parent_id=$1",
+		"DELETE FROM posts WHERE id=$1",
+	}
+
+	for _, query := range queries {
+		if _, err = tx.Exec(query, post.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Moves the board to the trash. Its posts stay as they are but can't be
+// seen while the board is gone.
+func (board *Board) SoftDelete(moderator *User, reason string) error {
+	board.DeletedAt = pq.NullTime{Time: time.Now(), Valid: true}
+	board.DeletedBy = sql.NullInt64{Int64: moderator.ID, Valid: true}
+	board.DeleteReason = reason
+	board.accessCache = nil
+
+	db := GetDbSession()
+	_, err := db.Update(board)
+	return err
+}
+
+func (board *Board) Restore() error {
+	board.DeletedAt = pq.NullTime{}
+	board.DeletedBy = sql.NullInt64{}
+	board.DeleteReason = ""
+	board.accessCache = nil
+
+	db := GetDbSession()
+	_, err := db.Update(board)
+	return err
+}
+
+// Removes the board and every post in it for good
+func (board *Board) Purge() error {
+	db := GetDbSession()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		"DELETE FROM views WHERE post_id IN (SELECT id FROM posts WHERE board_id=$1)",
+		"DELETE FROM posts WHERE board_id=$1 AND parent_id IS NOT NULL",
+		"DELETE FROM posts WHERE board_id=$1",
+		"DELETE FROM board_access WHERE board_id=$1",
+		"DELETE FROM board_moderators WHERE board_id=$1",
+		"DELETE FROM boards WHERE id=$1",
+	}
+
+	for _, query := range queries {
+		if _, err = tx.Exec(query, board.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Returns the posts in the trash on boards where the user may delete
+// posts, newest first. Replies which went to the trash along with their
+// thread aren't listed separately.
+func GetTrashedPosts(user *User) ([]*Post, error) {
+	db := GetDbSession()
+
+	var posts []*Post
+	_, err := db.Select(&posts, `
+        SELECT posts.*
+        FROM posts
+        LEFT OUTER JOIN posts AS op ON op.id=posts.parent_id
+        WHERE
+            posts.deleted_at IS NOT NULL AND
+            (op.id IS NULL OR op.deleted_at IS NULL OR op.deleted_at<>posts.deleted_at)
+        ORDER BY posts.deleted_at DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+
+	boards := map[int64]*Board{}
+	var allowed []*Post
+	for _, post := range posts {
+		board, ok := boards[post.BoardID]
+		if !ok {
+			board, _ = GetBoard(int(post.BoardID))
+			boards[post.BoardID] = board
+		}
+
+		if board != nil && Can(user, PermPostDelete, board) {
+			allowed = append(allowed, post)
+		}
+	}
+
+	return allowed, nil
+}
+
+// Returns the boards in the trash, newest first
+func GetTrashedBoards() ([]*Board, error) {
+	db := GetDbSession()
+
+	var boards []*Board
+	_, err := db.Select(&boards, "SELECT * FROM boards WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+
+	return boards, err
+}
+
+// Whether the user should see the trash at all
+func (user *User) CanViewTrash() bool {
+	if CanAny(user, nil, PermPostDelete, PermBoardManage, PermTrashPurge) {
+		return true
+	}
+
+	db := GetDbSession()
+	count, _ := db.SelectInt("SELECT COUNT(*) FROM board_moderators WHERE user_id=$1", user.ID)
+	return count > 0
+}
+
+// Returns how long things stay in the trash before they're purged, or 0
+// if they're kept until an admin purges them
+func GetTrashRetention() time.Duration {
+	days, err := config.Config.GetInt64("gobb", "trash_retention")
+	if err != nil || days <= 0 {
+		return 0
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Purges everything which went to the trash before the cutoff. Returns
+// the number of threads, posts and boards removed.
+func PurgeTrashBefore(cutoff time.Time) (int, error) {
+	db := GetDbSession()
+	purged := 0
+
+	var boards []*Board
+	_, err := db.Select(&boards, "SELECT * FROM boards WHERE deleted_at < $1", cutoff)
+	if err != nil {
+		return purged, err
+	}
+
+	for _, board := range boards {
+		if err = board.Purge(); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	// Threads first, so their replies go with them
+	var posts []*Post
+	_, err = db.Select(&posts, "SELECT * FROM posts WHERE deleted_at < $1 ORDER BY parent_id NULLS FIRST", cutoff)
+	if err != nil {
+		return purged, err
+	}
+
+	gone := map[int64]bool{}
+	for _, post := range posts {
+		if post.ParentID.Valid && gone[post.ParentID.Int64] {
+			continue
+		}
+
+		if err = post.Purge(); err != nil {
+			return purged, err
+		}
+		gone[post.ID] = true
+		purged++
+	}
+
+	return purged, nil
+}
+
+// Periodically purges whatever has been in the trash for longer than the
+// retention period. Does nothing if no retention period is configured.
+func StartTrashPurger() {
+	retention := GetTrashRetention()
+	if retention == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			purged, err := PurgeTrashBefore(time.Now().Add(-retention))
+			if err != nil {
+				fmt.Printf("[error] Could not empty the trash (%s)\n", err.Error())
+			} else if purged > 0 {
+				fmt.Printf("[notice] Purged %d items from the trash\n", purged)
+			}
+
+			time.Sleep(time.Hour)
+		}
+	}()
+}
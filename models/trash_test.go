@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestSoftDeleteCascade(t *testing.T) {
+	db := requireTestDB(t)
+	user, board := newTestUserAndBoard(t, db)
+
+	thread := newTestPost(t, db, user, board, nil)
+	reply := newTestPost(t, db, user, board, thread)
+	earlier := newTestPost(t, db, user, board, thread)
+	if err := earlier.SoftDelete(user, "Deleted on its own"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := thread.SoftDelete(user, "Deleted with the thread"); err != nil {
+		t.Fatal(err)
+	}
+	if err := thread.SoftDelete(user, "Again"); err == nil {
+		t.Error("Deleting a post twice didn't fail")
+	}
+
+	reply, _ = GetPost(int(reply.ID))
+	if !reply.IsDeleted() || !reply.DeletedAt.Time.Equal(thread.DeletedAt.Time) {
+		t.Errorf("Reply wasn't deleted with its thread: %v", reply.DeletedAt)
+	}
+	if err := reply.Restore(); err == nil {
+		t.Error("Restored a reply whose thread is in the trash")
+	}
+
+	if err := thread.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, _ = GetPost(int(reply.ID))
+	if reply.IsDeleted() {
+		t.Error("Restoring the thread didn't bring back its reply")
+	}
+
+	earlier, _ = GetPost(int(earlier.ID))
+	if !earlier.IsDeleted() || earlier.DeleteReason != "Deleted on its own" {
+		t.Error("Restoring the thread brought back a reply deleted before it")
+	}
+}
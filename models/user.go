@@ -1,16 +1,11 @@
 package models
 
 import (
-	"crypto/rand"
-	"crypto/sha1"
 	"database/sql"
-	"encoding/base64"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -31,17 +26,52 @@ type User struct {
 	LastSeen      time.Time      `db:"last_seen"`
 	HideOnline    bool           `db:"hide_online"`
 	LastUnreadAll pq.NullTime    `db:"last_unread_all"`
+
+	// Which kinds of notification the user wants
+	NotifyMentions bool `db:"notify_mentions"`
+	NotifyQuotes   bool `db:"notify_quotes"`
+	NotifyReplies  bool `db:"notify_replies"`
+
+	// Set up from the user's settings; see email.go
+	Email              string      `db:"email"`
+	EmailVerified      bool        `db:"email_verified"`
+	EmailToken         string      `db:"email_token"`
+	EmailNotifications bool        `db:"email_notifications"`
+	EmailDigest        string      `db:"email_digest"`
+	LastDigest         pq.NullTime `db:"last_digest"`
+	VerificationSentOn pq.NullTime `db:"verification_sent_on"`
 }
 
-func NewUser(username, password string) *User {
+func (user *User) GetGroup() *Group {
+	group, err := GetGroup(int(user.GroupID))
+	if err != nil || group == nil {
+		return &Group{ID: user.GroupID}
+	}
+
+	return group
+}
+
+func NewUser(username, password string) (*User, error) {
 	user := &User{
+		GroupID:   DefaultGroupID,
 		CreatedOn: time.Now(),
 		Username:  username,
 		LastSeen:  time.Now(),
+
+		NotifyMentions: true,
+		NotifyQuotes:   true,
+		NotifyReplies:  true,
+
+		EmailNotifications: true,
+		EmailDigest:        DigestOff,
 	}
 
-	user.SetPassword(password)
-	return user
+	err := user.SetPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
 func AuthenticateUser(username, password string) (*User, error) {
@@ -57,15 +87,18 @@ func AuthenticateUser(username, password string) (*User, error) {
 		return nil, errors.New("Inval username/password")
 	}
 
-	hasher := sha1.New()
-	io.WriteString(hasher, password)
-	io.WriteString(hasher, user.Salt)
-	password = base64.URLEncoding.EncodeToString(hasher.Sum(nil))
-
-	if password != user.Password {
+	if !user.CheckPassword(password) {
 		return nil, errors.New("Inval username/password")
 	}
 
+	// Upgrade old or weak hashes now that we know the plaintext
+	if user.PasswordNeedsRehash() {
+		err = user.SetPassword(password)
+		if err != nil {
+			log.Printf("[error] Could not rehash password for '%s' (%s)\n", username, err.Error())
+		}
+	}
+
 	// Update the user's last seen
 	user.LastSeen = time.Now()
 	db.Update(user)
@@ -120,39 +153,85 @@ func GetUser(ID int) (*User, error) {
 	return obj.(*User), err
 }
 
-// Converts the given string into an appropriate hash, resets the salt,
-// and sets the Password attribute. Does *not* commit to the database.
-func (user *User) SetPassword(password string) {
-	var int_salt int32
-	binary.Read(rand.Reader, binary.LittleEndian, &int_salt)
-	salt := strconv.Itoa(int(int_salt))
+func GetUserByUsername(username string) (*User, error) {
+	db := GetDbSession()
+	user := &User{}
+	err := db.SelectOne(user, "SELECT * FROM users WHERE username=$1", username)
+	if err != nil {
+		return nil, err
+	}
 
-	hasher := sha1.New()
-	io.WriteString(hasher, password)
-	io.WriteString(hasher, salt)
-	user.Password = base64.URLEncoding.EncodeToString(hasher.Sum(nil))
-	user.Salt = salt
+	return user, nil
 }
 
-func (user *User) IsAdmin() bool {
-	if user.GroupID == 2 {
-		return true
+// Looks up the users with the given usernames in one go, keyed by
+// username. Names nobody has are left out.
+func getUsersByUsername(usernames []string) (map[string]*User, error) {
+	users := map[string]*User{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	placeholders := make([]string, len(usernames))
+	args := make([]interface{}, len(usernames))
+	for i, username := range usernames {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = username
+	}
+
+	db := GetDbSession()
+	var found []*User
+	_, err := db.Select(&found, "SELECT * FROM users WHERE username IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range found {
+		users[user.Username] = user
+	}
+
+	return users, nil
+}
+
+// Converts the given string into a hash using the default password hasher
+// and sets the Password attribute. The salt is kept inside the hash itself,
+// so the legacy Salt column is cleared. Does *not* commit to the database.
+func (user *User) SetPassword(password string) error {
+	hash, err := GetDefaultPasswordHasher().Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hash
+	user.Salt = ""
+	return nil
+}
+
+// Checks the given plaintext against the user's stored hash, whichever
+// format it happens to be in.
+func (user *User) CheckPassword(password string) bool {
+	hasher := getPasswordHasherFor(user.Password)
+	if hasher == nil {
+		return verifyLegacyPassword(password, user.Salt, user.Password)
 	}
 
-	return false
+	return hasher.Verify(password, user.Password)
 }
 
-func (user *User) CanModerate() bool {
-	if user.GroupID > 0 {
+// Returns true if the stored hash is in the legacy format, was made by
+// something other than the default hasher or uses outdated parameters.
+func (user *User) PasswordNeedsRehash() bool {
+	hasher := getPasswordHasherFor(user.Password)
+	if hasher == nil || hasher.ID() != GetDefaultPasswordHasher().ID() {
 		return true
 	}
 
-	return false
+	return hasher.NeedsRehash(user.Password)
 }
 
 func (user *User) GetPostCount() int64 {
 	db := GetDbSession()
-	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE author_id=$1", user.ID)
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE author_id=$1 AND deleted_at IS NULL AND NOT pending", user.ID)
 
 	if err != nil {
 		return 0
@@ -161,17 +240,42 @@ func (user *User) GetPostCount() int64 {
 	return count
 }
 
-func (user *User) GetPosts(page int) []*Post {
+// Returns the index of the last page of the user's posts that the viewer
+// is allowed to read
+func (user *User) GetPagesOfPosts(viewer *User) int {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM posts WHERE author_id=$1 AND deleted_at IS NULL AND NOT pending AND "+postReadableSQL(viewer), user.ID)
+	if err != nil {
+		log.Printf("[error] Could not count user's posts (%s)", err.Error())
+	}
+
+	postsPerPage, err := config.Config.GetInt64("gobb", "posts_per_page")
+	if err != nil || postsPerPage <= 0 {
+		postsPerPage = 15
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return int((count - 1) / postsPerPage)
+}
+
+// Returns a page of the user's posts, leaving out anything on boards the
+// viewer isn't allowed to read.
+func (user *User) GetPosts(page int, viewer *User) []*Post {
 	db := GetDbSession()
 	var posts []*Post
 
 	postsPerPage, _ := config.Config.GetInt64("gobb", "posts_per_page")
 	offset := postsPerPage * int64(page)
 
-	_, err := db.Select(&posts, "SELECT * FROM posts WHERE author_id=$1 ORDER BY created_on DESC LIMIT $2 OFFSET $3", user.ID, postsPerPage, offset)
+	_, err := db.Select(&posts, "SELECT * FROM posts WHERE author_id=$1 AND deleted_at IS NULL AND NOT pending AND "+postReadableSQL(viewer)+" ORDER BY created_on DESC LIMIT $2 OFFSET $3", user.ID, postsPerPage, offset)
 
 	if err != nil {
 		log.Printf("[error] Could not get user's posts (%s)", err.Error())
+	} else if err = LoadEditHistory(posts); err != nil {
+		log.Printf("[error] Could not get edit history (%s)", err.Error())
 	}
 
 	return posts
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Events a webhook can be subscribed to
+const (
+	WebhookThreadCreate = "thread.create"
+	WebhookPostReply    = "post.reply"
+	WebhookPostEdit     = "post.edit"
+	WebhookPostDelete   = "post.delete"
+	WebhookUserRegister = "user.register"
+	WebhookThreadLock   = "thread.lock"
+	WebhookThreadUnlock = "thread.unlock"
+	WebhookThreadMove   = "thread.move"
+)
+
+var WebhookEvents = []string{
+	WebhookThreadCreate,
+	WebhookPostReply,
+	WebhookPostEdit,
+	WebhookPostDelete,
+	WebhookUserRegister,
+	WebhookThreadLock,
+	WebhookThreadUnlock,
+	WebhookThreadMove,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// A delivery is given up on after this many tries
+	webhookMaxAttempts = 8
+	// Wait before the first retry, doubled after every failure after that
+	webhookRetryDelay = 30 * time.Second
+	webhookTimeout    = 10 * time.Second
+	// How many due deliveries the worker picks up at once
+	webhookBatchSize = 20
+	// How much of a failed response is kept in the delivery log
+	webhookMaxErrorLength = 1024
+
+	deliveriesPerPage = 50
+)
+
+// An endpoint that is sent a signed JSON payload whenever one of the
+// events it's subscribed to happens
+type Webhook struct {
+	ID        int64         `db:"id"`
+	URL       string        `db:"url"`
+	Secret    string        `db:"secret"`
+	Events    string        `db:"events"`
+	Active    bool          `db:"active"`
+	CreatedBy sql.NullInt64 `db:"created_by"`
+	CreatedOn time.Time     `db:"created_on"`
+}
+
+// One attempt, or series of attempts, at sending an event to a webhook
+type WebhookDelivery struct {
+	ID           int64       `db:"id"`
+	WebhookID    int64       `db:"webhook_id"`
+	Event        string      `db:"event"`
+	Payload      string      `db:"payload"`
+	Status       string      `db:"status"`
+	Attempts     int         `db:"attempts"`
+	NextAttempt  time.Time   `db:"next_attempt"`
+	LastAttempt  pq.NullTime `db:"last_attempt"`
+	ResponseCode int         `db:"response_code"`
+	Error        string      `db:"error"`
+	CreatedOn    time.Time   `db:"created_on"`
+}
+
+// Wakes the worker up when there's something new to send
+var webhookWake = make(chan struct{}, 1)
+
+func NewWebhook(creator *User, rawURL, secret string, events []string) *Webhook {
+	webhook := &Webhook{
+		URL:       strings.TrimSpace(rawURL),
+		Secret:    strings.TrimSpace(secret),
+		Active:    true,
+		CreatedBy: sql.NullInt64{Int64: creator.ID, Valid: true},
+		CreatedOn: time.Now(),
+	}
+	webhook.SetEvents(events)
+
+	return webhook
+}
+
+// Makes a random secret for admins who don't bring their own
+func GenerateWebhookSecret() string {
+	raw := make([]byte, 24)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+func (webhook *Webhook) Validate() error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("Webhook URLs must be absolute http or https URLs")
+	}
+
+	if len(webhook.URL) > 2048 {
+		return errors.New("Webhook URLs must be at most 2048 characters")
+	}
+
+	if len(webhook.Secret) < 16 || len(webhook.Secret) > 128 {
+		return errors.New("Secrets must be between 16 and 128 characters")
+	}
+
+	if webhook.Events == "" {
+		return errors.New("Pick at least one event")
+	}
+
+	return nil
+}
+
+// Keeps only the events we know about, in a stable order
+func (webhook *Webhook) SetEvents(events []string) {
+	wanted := map[string]bool{}
+	for _, event := range events {
+		wanted[event] = true
+	}
+
+	var kept []string
+	for _, event := range WebhookEvents {
+		if wanted[event] {
+			kept = append(kept, event)
+		}
+	}
+
+	webhook.Events = strings.Join(kept, ",")
+}
+
+func (webhook *Webhook) GetEvents() []string {
+	if webhook.Events == "" {
+		return nil
+	}
+
+	return strings.Split(webhook.Events, ",")
+}
+
+func (webhook *Webhook) HasEvent(event string) bool {
+	for _, e := range webhook.GetEvents() {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func GetWebhook(ID int) (*Webhook, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&Webhook{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*Webhook), err
+}
+
+func GetWebhooks() ([]*Webhook, error) {
+	db := GetDbSession()
+
+	var webhooks []*Webhook
+	_, err := db.Select(&webhooks, "SELECT * FROM webhooks ORDER BY id ASC")
+
+	return webhooks, err
+}
+
+func (webhook *Webhook) Delete() error {
+	db := GetDbSession()
+	_, err := db.Delete(webhook)
+	return err
+}
+
+// Counts deliveries by status, for the admin list
+func (webhook *Webhook) CountDeliveries(status string) int64 {
+	db := GetDbSession()
+	count, err := db.SelectInt("SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id=$1 AND status=$2", webhook.ID, status)
+	if err != nil {
+		return 0
+	}
+
+	return count
+}
+
+// Returns a page of the webhook's deliveries, newest first, along with
+// the number of pages
+func (webhook *Webhook) GetDeliveries(page int) ([]*WebhookDelivery, int, error) {
+	db := GetDbSession()
+
+	count, err := db.SelectInt("SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id=$1", webhook.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []*WebhookDelivery
+	_, err = db.Select(&deliveries, "SELECT * FROM webhook_deliveries WHERE webhook_id=$1 ORDER BY created_on DESC, id DESC LIMIT $2 OFFSET $3", webhook.ID, deliveriesPerPage, page*deliveriesPerPage)
+
+	pages := int(math.Ceil(float64(count) / float64(deliveriesPerPage)))
+	return deliveries, pages, err
+}
+
+func GetWebhookDelivery(ID int) (*WebhookDelivery, error) {
+	db := GetDbSession()
+	obj, err := db.Get(&WebhookDelivery{}, ID)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*WebhookDelivery), err
+}
+
+// Queues the event for every active webhook subscribed to it. The data
+// is sent as the payload's "data" field. Nothing is sent from here; the
+// worker picks the deliveries up in the background.
+func QueueWebhookEvent(event string, data interface{}) {
+	webhooks, err := GetWebhooks()
+	if err != nil {
+		fmt.Printf("[error] Could not get webhooks (%s)\n", err.Error())
+		return
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      event,
+		"created_on": now,
+		"data":       data,
+	})
+	if err != nil {
+		fmt.Printf("[error] Could not encode webhook payload (%s)\n", err.Error())
+		return
+	}
+
+	db := GetDbSession()
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Active || !webhook.HasEvent(event) {
+			continue
+		}
+
+		err := db.Insert(&WebhookDelivery{
+			WebhookID:   webhook.ID,
+			Event:       event,
+			Payload:     string(payload),
+			Status:      DeliveryPending,
+			NextAttempt: now,
+			CreatedOn:   now,
+		})
+		if err != nil {
+			fmt.Printf("[error] Could not queue webhook delivery (%s)\n", err.Error())
+			continue
+		}
+
+		queued = true
+	}
+
+	if queued {
+		select {
+		case webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Signs a payload with the webhook's secret. Receivers should compute the
+// same HMAC over the raw body and compare it to the X-Gobb-Signature
+// header.
+func (webhook *Webhook) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Queues the delivery to be sent again straight away
+func (delivery *WebhookDelivery) Retry() error {
+	delivery.Status = DeliveryPending
+	delivery.NextAttempt = time.Now()
+
+	db := GetDbSession()
+	_, err := db.Update(delivery)
+
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+
+	return err
+}
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// Makes one attempt at sending the delivery and records the outcome,
+// scheduling another attempt if it failed and there are tries left.
+// Deliveries for webhooks which have since been turned off are given up
+// on without being sent. Returns an error if the outcome couldn't be
+// saved.
+func (delivery *WebhookDelivery) attempt() error {
+	db := GetDbSession()
+
+	webhook, _ := GetWebhook(int(delivery.WebhookID))
+	if webhook == nil || !webhook.Active {
+		delivery.Status = DeliveryFailed
+		delivery.Error = "The webhook was turned off before this could be sent"
+		_, err := db.Update(delivery)
+		return err
+	}
+
+	delivery.Attempts++
+	delivery.LastAttempt = pq.NullTime{Time: time.Now(), Valid: true}
+	delivery.ResponseCode = 0
+	delivery.Error = ""
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "GoBB-Webhooks")
+		req.Header.Set("X-Gobb-Event", delivery.Event)
+		req.Header.Set("X-Gobb-Delivery", strconv.FormatInt(delivery.ID, 10))
+		req.Header.Set("X-Gobb-Signature", webhook.Sign(body))
+
+		var resp *http.Response
+		resp, err = webhookClient.Do(req)
+		if err == nil {
+			delivery.ResponseCode = resp.StatusCode
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, webhookMaxErrorLength))
+				err = fmt.Errorf("%s: %s", resp.Status, snippet)
+			}
+			resp.Body.Close()
+		}
+	}
+
+	if err == nil {
+		delivery.Status = DeliveryDelivered
+	} else {
+		// The response body can hold anything, including bytes Postgres
+		// won't store
+		delivery.Error = TruncateText(err.Error(), webhookMaxErrorLength)
+
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = DeliveryFailed
+		} else {
+			// 30s, 1m, 2m, 4m... between tries
+			backoff := webhookRetryDelay * time.Duration(1<<uint(delivery.Attempts-1))
+			delivery.NextAttempt = time.Now().Add(backoff)
+		}
+	}
+
+	_, err = db.Update(delivery)
+	return err
+}
+
+// Gives up on a delivery whose outcome couldn't be saved. Left pending it
+// would be sent again on every tick, ahead of everything queued after it.
+func (delivery *WebhookDelivery) giveUp() error {
+	db := GetDbSession()
+	_, err := db.Exec("UPDATE webhook_deliveries SET status=$1, error=$2 WHERE id=$3", DeliveryFailed, "The outcome of this delivery couldn't be saved", delivery.ID)
+	return err
+}
+
+// Sends every delivery that's due, returning how many there were
+func deliverDueWebhooks() int {
+	db := GetDbSession()
+
+	var due []*WebhookDelivery
+	_, err := db.Select(&due, "SELECT * FROM webhook_deliveries WHERE status=$1 AND next_attempt <= $2 ORDER BY next_attempt ASC LIMIT $3", DeliveryPending, time.Now(), webhookBatchSize)
+	if err != nil {
+		fmt.Printf("[error] Could not get due webhook deliveries (%s)\n", err.Error())
+		return 0
+	}
+
+	for _, delivery := range due {
+		if err := delivery.attempt(); err != nil {
+			fmt.Printf("[error] Could not save webhook delivery %d (%s)\n", delivery.ID, err.Error())
+			if err = delivery.giveUp(); err != nil {
+				fmt.Printf("[error] Could not give up on webhook delivery %d (%s)\n", delivery.ID, err.Error())
+				return 0
+			}
+		}
+	}
+
+	return len(due)
+}
+
+// Starts sending queued webhook deliveries in the background. New events
+// wake the worker straight away; retries are picked up every few seconds.
+func StartWebhookWorker() {
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		for {
+			// Keep going while there's a backlog
+			for {
+				if deliverDueWebhooks() < webhookBatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-webhookWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -37,10 +37,74 @@ var Sirjtaa = (function() {
         $(this).parent().children(".prec_slash").hide();
     }
 
+    function bindPost(post) {
+        post.find(".quote-post").on("click", quotePostClicked);
+        post.find(".delete").on("click", clickConfirmDelete);
+        post.find(".moderate").on("click", clickModerate);
+    }
+
+    // Fetches a post the way it's rendered on its page of the thread
+    function loadPost(event, callback) {
+        $.get(window.location.pathname + "?page=" + event.page, function(html) {
+            var post = $("<div>").append($.parseHTML(html)).find("#post_" + event.post_id);
+            if(post.length == 0) {
+                return;
+            }
+
+            bindPost(post);
+            callback(post);
+        });
+    }
+
+    // Keeps the thread up to date as people reply to and edit posts in it
+    function watchThread() {
+        var end = $("#thread-end");
+        if(end.length == 0 || !window.EventSource) {
+            return;
+        }
+
+        var page = end.data("page");
+        var newReplies = 0;
+        var events = new EventSource(end.data("events"));
+
+        events.addEventListener("reply", function(e) {
+            var event = JSON.parse(e.data);
+            if($("#post_" + event.post_id).length > 0) {
+                return;
+            }
+
+            // Replies that land on this page are shown straight away
+            if(event.page == page) {
+                loadPost(event, function(post) {
+                    post.insertBefore(end);
+                });
+                return;
+            }
+
+            newReplies++;
+            $("#new-replies").show().children("a")
+                .attr("href", window.location.pathname + "?page=" + event.page + "#post_" + event.post_id)
+                .text(newReplies + (newReplies == 1 ? " new reply" : " new replies"));
+        });
+
+        events.addEventListener("edit", function(e) {
+            var event = JSON.parse(e.data);
+            var existing = $("#post_" + event.post_id);
+            if(existing.length == 0) {
+                return;
+            }
+
+            loadPost(event, function(post) {
+                existing.replaceWith(post);
+            });
+        });
+    }
+
     $(document).ready(function() {
         $(".quote-post").on("click", quotePostClicked);
         $(".thread-reply-btn").on("click", threadReplyClicked);
         $(".delete").on("click", clickConfirmDelete);
         $(".moderate").on("click", clickModerate);
+        watchThread();
     });
 })();
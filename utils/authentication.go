@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/sessions"
@@ -15,30 +18,154 @@ func GetCookieStore(r *http.Request) *sessions.CookieStore {
 	if Store == nil {
 		cookieKey, _ := config.Config.GetString("gobb", "cookie_key")
 		Store = sessions.NewCookieStore([]byte(cookieKey))
+		Store.Options.HttpOnly = true
 	}
 
 	return Store
 }
 
-func GetCurrentUser(r *http.Request) *models.User {
-	cached := context.Get(r, "user")
+// Returns the address of the client making the request. X-Real-IP is
+// only trusted when behind_proxy is enabled in the config.
+func GetRemoteIP(r *http.Request) string {
+	behindProxy, _ := config.Config.GetBool("gobb", "behind_proxy")
+	if behindProxy {
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Creates a new server-side session for the user and stores its token in
+// the sirsid cookie.
+func StartSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	session, token, err := models.NewSession(user, r.UserAgent(), GetRemoteIP(r))
+	if err != nil {
+		return err
+	}
+
+	// Clean up after anyone whose session ran out in the meantime
+	models.DeleteExpiredSessions()
+
+	cookie, _ := GetCookieStore(r).Get(r, "sirsid")
+	cookie.Values["token"] = token
+	// Cookies set by older versions held the plaintext credentials
+	delete(cookie.Values, "username")
+	delete(cookie.Values, "password")
+
+	context.Set(r, "session", session)
+	context.Set(r, "user", user)
+	return cookie.Save(r, w)
+}
+
+// Revokes the current session and clears the token from the cookie
+func EndSession(w http.ResponseWriter, r *http.Request) error {
+	if session := GetCurrentSession(r); session != nil {
+		session.Revoke()
+	}
+
+	cookie, _ := GetCookieStore(r).Get(r, "sirsid")
+	delete(cookie.Values, "token")
+
+	context.Clear(r)
+	return cookie.Save(r, w)
+}
+
+// Returns the server-side session belonging to the request's cookie, or
+// nil if the visitor isn't logged in.
+func GetCurrentSession(r *http.Request) *models.Session {
+	cached := context.Get(r, "session")
 	if cached != nil {
-		return cached.(*models.User)
+		return cached.(*models.Session)
+	}
+
+	cookie, _ := GetCookieStore(r).Get(r, "sirsid")
+	token, ok := cookie.Values["token"].(string)
+	if !ok {
+		return nil
+	}
+
+	session, err := models.GetSessionByToken(token)
+	if err != nil {
+		return nil
 	}
 
-	session, _ := GetCookieStore(r).Get(r, "sirsid")
-	sessionUsername := session.Values["username"]
-	sessionPassword := session.Values["password"]
+	session.Touch(GetRemoteIP(r))
+	context.Set(r, "session", session)
+	return session
+}
 
-	if sessionUsername == nil || sessionPassword == nil {
+// Whether the request is for the JSON API, which is the only place API
+// tokens are accepted
+func IsAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// Returns the API token sent in an "Authorization: Bearer" header, or nil
+// if there isn't a valid one. Tokens are ignored outside of the API.
+func GetCurrentAPIToken(r *http.Request) *models.APIToken {
+	cached := context.Get(r, "api_token")
+	if cached != nil {
+		return cached.(*models.APIToken)
+	}
+
+	if !IsAPIRequest(r) {
+		return nil
+	}
+
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
 		return nil
 	}
 
-	currentUser, err := models.AuthenticateUser(sessionUsername.(string), sessionPassword.(string))
+	token, err := models.GetAPITokenByToken(strings.TrimSpace(header[7:]))
 	if err != nil {
 		return nil
 	}
 
+	token.Touch(GetRemoteIP(r))
+	context.Set(r, "api_token", token)
+	return token
+}
+
+// Returns the logged in user. API requests carrying a bearer token are
+// authenticated by the token alone, and never fall back to the cookie.
+func GetCurrentUser(r *http.Request) *models.User {
+	cached := context.Get(r, "user")
+	if cached != nil {
+		return cached.(*models.User)
+	}
+
+	var userID int64
+	if IsAPIRequest(r) && r.Header.Get("Authorization") != "" {
+		token := GetCurrentAPIToken(r)
+		if token == nil {
+			return nil
+		}
+		userID = token.UserID
+	} else {
+		session := GetCurrentSession(r)
+		if session == nil {
+			return nil
+		}
+		userID = session.UserID
+	}
+
+	currentUser, err := models.GetUser(int(userID))
+	if err != nil || currentUser == nil {
+		return nil
+	}
+
+	// Update the user's last seen
+	currentUser.LastSeen = time.Now()
+	models.GetDbSession().Update(currentUser)
+
 	context.Set(r, "user", currentUser)
 	return currentUser
 }
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+)
+
+// Returns the CSRF token tied to the current session. Visitors who aren't
+// logged in don't have one.
+func GetCSRFToken(r *http.Request) string {
+	session := GetCurrentSession(r)
+	if session == nil {
+		return ""
+	}
+
+	return session.CSRFToken
+}
+
+// Checks the csrf_token form value (or the X-CSRF-Token header, for
+// scripts) against the current session. Every handler that changes
+// something on behalf of a logged in user must call this.
+func CheckCSRF(r *http.Request) bool {
+	expected := GetCSRFToken(r)
+	if expected == "" {
+		return false
+	}
+
+	given := r.Header.Get("X-CSRF-Token")
+	if given == "" {
+		given = r.PostFormValue("csrf_token")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// Writes a 403 response if the request doesn't carry a valid CSRF token.
+// Returns true if the handler should carry on.
+func RequireCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if !CheckCSRF(r) {
+		http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// Same as RequireCSRF, but also rejects anything other than a POST. Used
+// by the /action/* handlers, which have no page of their own to render.
+func RequirePOSTWithCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	return RequireCSRF(w, r)
+}
+
+func tplCSRFField(r *http.Request) func() template.HTML {
+	return func() template.HTML {
+		return template.HTML(`<input type="hidden" name="csrf_token" value="` + template.HTMLEscapeString(GetCSRFToken(r)) + `" />`)
+	}
+}
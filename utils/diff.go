@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of DiffOp
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// Above this many comparisons the diff gives up and shows the whole of
+// the old text as removed and the new text as added, so huge posts can't
+// tie up the server
+const maxDiffCells = 250000
+
+// A run of text which is the same in both versions, or only in one
+type DiffOp struct {
+	Kind string
+	Text string
+}
+
+var wordPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// Compares two versions of a text line by line. Each op holds one or more
+// whole lines, including their newlines.
+func DiffLines(before, after string) []DiffOp {
+	return diffTokens(strings.SplitAfter(before, "\n"), strings.SplitAfter(after, "\n"))
+}
+
+// Compares two versions of a text word by word. Whitespace counts as a
+// word of its own so the ops can be joined back into the original text.
+func DiffWords(before, after string) []DiffOp {
+	return diffTokens(wordPattern.FindAllString(before, -1), wordPattern.FindAllString(after, -1))
+}
+
+func diffTokens(a, b []string) []DiffOp {
+	var ops []DiffOp
+	add := func(kind, text string) {
+		if text == "" {
+			return
+		}
+
+		// Merge neighbouring ops of the same kind
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Text += text
+			return
+		}
+
+		ops = append(ops, DiffOp{Kind: kind, Text: text})
+	}
+
+	// Most edits touch a small part of a post, so strip what's the same
+	// at both ends before doing the expensive part
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	if len(midA)*len(midB) > maxDiffCells {
+		add(DiffDelete, strings.Join(a, ""))
+		add(DiffInsert, strings.Join(b, ""))
+		return ops
+	}
+
+	add(DiffEqual, strings.Join(a[:prefix], ""))
+
+	// Longest common subsequence, built from the end so it can be
+	// walked forwards
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(midA) && j < len(midB) {
+		switch {
+		case midA[i] == midB[j]:
+			add(DiffEqual, midA[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(DiffDelete, midA[i])
+			i++
+		default:
+			add(DiffInsert, midB[j])
+			j++
+		}
+	}
+
+	add(DiffDelete, strings.Join(midA[i:], ""))
+	add(DiffInsert, strings.Join(midB[j:], ""))
+
+	add(DiffEqual, strings.Join(a[len(a)-suffix:], ""))
+
+	return ops
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	got := DiffWords("the quick fox", "the slow fox")
+	want := []DiffOp{
+		{DiffEqual, "the "},
+		{DiffDelete, "quick"},
+		{DiffInsert, "slow"},
+		{DiffEqual, " fox"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffWords() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffTooLarge(t *testing.T) {
+	var before, after []string
+	for i := 0; i < 1000; i++ {
+		before = append(before, "a\n")
+		after = append(after, "b\n")
+	}
+	a := "same\n" + strings.Join(before, "")
+	b := "same\n" + strings.Join(after, "")
+
+	// Past the limit the whole of both versions is shown, with nothing
+	// picked out as unchanged
+	got := DiffLines(a, b)
+	want := []DiffOp{{DiffDelete, a}, {DiffInsert, b}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffLines() on a huge edit gave %d ops, want a plain before and after", len(got))
+	}
+}
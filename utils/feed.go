@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stevenleeg/gobb/config"
+)
+
+// A feed of posts, which can be written out as either Atom or RSS 2.0.
+// Links should already be absolute; see AbsoluteURL.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string
+	// Where the feed itself lives, without the .atom or .rss extension
+	SelfLink string
+	Updated  time.Time
+	Entries  []*FeedEntry
+}
+
+type FeedEntry struct {
+	Title     string
+	Link      string
+	Author    string
+	AuthorURL string
+	// Rendered, sanitized HTML
+	Content   string
+	Published time.Time
+	Updated   time.Time
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomPerson  `xml:"author"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator"`
+	Description string  `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// Turns a path on this site into a full URL using the base_url config.
+// base_url normally leaves out the scheme, in which case http is assumed.
+func AbsoluteURL(path string) string {
+	baseURL, _ := config.Config.GetString("gobb", "base_url")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+func (feed *Feed) toAtom() *atomFeed {
+	out := &atomFeed{
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		ID:       feed.SelfLink + ".atom",
+		Updated:  feed.Updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+			{Href: feed.SelfLink + ".atom", Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+
+	for _, entry := range feed.Entries {
+		out.Entries = append(out.Entries, atomEntry{
+			Title:     entry.Title,
+			ID:        entry.Link,
+			Link:      atomLink{Href: entry.Link, Rel: "alternate", Type: "text/html"},
+			Published: entry.Published.Format(time.RFC3339),
+			Updated:   entry.Updated.Format(time.RFC3339),
+			Author:    atomPerson{Name: entry.Author, URI: entry.AuthorURL},
+			Content:   atomContent{Type: "html", Body: entry.Content},
+		})
+	}
+
+	return out
+}
+
+func (feed *Feed) toRSS() *rssFeed {
+	out := &rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Description,
+			LastBuildDate: feed.Updated.Format(time.RFC1123Z),
+			AtomLink:      atomLink{Href: feed.SelfLink + ".rss", Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+
+	// RSS requires a description, even if it's just the title again
+	if out.Channel.Description == "" {
+		out.Channel.Description = feed.Title
+	}
+
+	for _, entry := range feed.Entries {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: true, Value: entry.Link},
+			PubDate:     entry.Published.Format(time.RFC1123Z),
+			Creator:     entry.Author,
+			Description: entry.Content,
+		})
+	}
+
+	return out
+}
+
+// Writes the feed as Atom or RSS depending on the format, which is the
+// extension from the feed's URL
+func RenderFeed(w http.ResponseWriter, feed *Feed, format string) {
+	var doc interface{}
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		doc = feed.toRSS()
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		doc = feed.toAtom()
+	}
+
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		fmt.Printf("[error] Could not write feed (%s)\n", err.Error())
+	}
+}
@@ -0,0 +1,374 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stevenleeg/gobb/config"
+	"github.com/stevenleeg/gobb/models"
+)
+
+// Which emails an unsubscribe link turns off
+const (
+	UnsubscribeNotifications = "notifications"
+	UnsubscribeDigest        = "digest"
+	UnsubscribeAll           = "all"
+)
+
+const (
+	mailTimeout = 30 * time.Second
+	// How much of a post is quoted in a notification email
+	mailExcerptLength = 300
+)
+
+// Wakes the mail worker up when there's something new to send
+var mailWake = make(chan struct{}, 1)
+
+// Email is only sent once an SMTP server is set up in the [email] section
+// of the config
+func EmailEnabled() bool {
+	host, _ := config.Config.GetString("email", "smtp_host")
+	return host != ""
+}
+
+func getMailFrom() *mail.Address {
+	siteName, _ := config.Config.GetString("gobb", "site_name")
+	from, _ := config.Config.GetString("email", "from_address")
+	return &mail.Address{Name: siteName, Address: from}
+}
+
+// Builds the raw message for an email, headers and all
+func buildMailMessage(email *models.Email) []byte {
+	from := getMailFrom()
+
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at != -1 {
+		domain = from.Address[at+1:]
+	}
+
+	var msg bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", key, value)
+	}
+
+	header("From", from.String())
+	header("To", (&mail.Address{Address: email.ToAddress}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<gobb.%d.%d@%s>", email.ID, email.CreatedOn.Unix(), domain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	if email.UnsubscribeURL != "" {
+		// Lets mail clients unsubscribe with one click (RFC 8058)
+		header("List-Unsubscribe", "<"+email.UnsubscribeURL+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	msg.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&msg)
+	body.Write([]byte(strings.Replace(email.Body, "\n", "\r\n", -1)))
+	body.Close()
+
+	return msg.Bytes()
+}
+
+// Hands an email to the configured SMTP server. STARTTLS is used whenever
+// the server offers it.
+func sendMail(email *models.Email) error {
+	host, _ := config.Config.GetString("email", "smtp_host")
+	port, err := config.Config.GetInt64("email", "smtp_port")
+	if err != nil {
+		port = 25
+	}
+	username, _ := config.Config.GetString("email", "smtp_username")
+	password, _ := config.Config.GetString("email", "smtp_password")
+	requireTLS, _ := config.Config.GetBool("email", "require_tls")
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))), mailTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(mailTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	} else if requireTLS {
+		return errors.New("The SMTP server doesn't support STARTTLS")
+	}
+
+	if username != "" {
+		if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(getMailFrom().Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(email.ToAddress); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMailMessage(email)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func getUnsubscribeURL(user *models.User, list string) (string, error) {
+	token, err := user.NewUnsubscribeToken()
+	if err != nil {
+		return "", err
+	}
+
+	return AbsoluteURL(fmt.Sprintf("/unsubscribe/%s?list=%s", token, list)), nil
+}
+
+// Queues an email to the user with the usual greeting and a footer
+// explaining how to stop getting it
+func queueUserMail(user *models.User, subject, body, list string) error {
+	unsubscribeURL, err := getUnsubscribeURL(user, list)
+	if err != nil {
+		return err
+	}
+
+	siteName, _ := config.Config.GetString("gobb", "site_name")
+
+	var text bytes.Buffer
+	fmt.Fprintf(&text, "Hi %s,\n\n", user.Username)
+	text.WriteString(body)
+	fmt.Fprintf(&text, "\n-- \nYou're getting this email because of your settings on %s: %s\n", siteName, AbsoluteURL(fmt.Sprintf("/user/%d/settings", user.ID)))
+	fmt.Fprintf(&text, "To stop getting emails like this, visit %s\n", unsubscribeURL)
+
+	err = models.QueueEmail(user, "["+siteName+"] "+subject, text.String(), unsubscribeURL)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case mailWake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Sends a link which verifies the user's new address
+func QueueVerificationEmail(user *models.User, token string) error {
+	body := fmt.Sprintf("Please confirm that this is your email address by visiting the link below:\n\n%s\n\nIf you didn't add this address to your account, you can ignore this email.\n", AbsoluteURL("/verify_email/"+token))
+	return queueUserMail(user, "Verify your email address", body, UnsubscribeAll)
+}
+
+// Cuts a post down to its first few hundred characters
+func mailExcerpt(content string) string {
+	content = strings.TrimSpace(content)
+	runes := []rune(content)
+	if len(runes) > mailExcerptLength {
+		content = strings.TrimSpace(string(runes[:mailExcerptLength])) + "..."
+	}
+
+	return "    " + strings.Replace(content, "\n", "\n    ", -1)
+}
+
+func describeNotification(notification *models.Notification) string {
+	actor := "Someone"
+	if user := notification.GetActor(); user != nil {
+		actor = user.Username
+	}
+
+	title := ""
+	if thread := notification.GetThread(); thread != nil {
+		title = thread.Title
+	}
+
+	switch notification.Type {
+	case models.NotificationReport:
+		outcome := ""
+		if report := notification.GetReport(); report != nil {
+			outcome = report.DescribeOutcome()
+		}
+		return fmt.Sprintf("Your report about a post in \"%s\" was handled: %s", title, outcome)
+	case models.NotificationRejected:
+		reason := ""
+		if post := notification.GetPost(); post != nil && post.DeleteReason != "" {
+			reason = ": " + post.DeleteReason
+		}
+		return fmt.Sprintf("A moderator rejected your post in \"%s\"%s", title, reason)
+	case models.NotificationMention:
+		return fmt.Sprintf("%s mentioned you in \"%s\"", actor, title)
+	case models.NotificationQuote:
+		return fmt.Sprintf("%s quoted you in \"%s\"", actor, title)
+	}
+
+	return fmt.Sprintf("%s replied in \"%s\"", actor, title)
+}
+
+// Emails everyone who has had new notifications since the worker last ran,
+// one email per user
+func queueNotificationEmails() {
+	users, err := models.GetUsersWithUnemailedNotifications()
+	if err != nil {
+		fmt.Printf("[error] Could not get users to email (%s)\n", err.Error())
+		return
+	}
+
+	for _, user := range users {
+		notifications, err := user.GetUnemailedNotifications()
+		if err != nil {
+			fmt.Printf("[error] Could not get notifications to email (%s)\n", err.Error())
+			continue
+		}
+		if len(notifications) == 0 {
+			continue
+		}
+
+		var body bytes.Buffer
+		for _, notification := range notifications {
+			fmt.Fprintf(&body, "%s:\n\n", describeNotification(notification))
+			if post := notification.GetPost(); post != nil && notification.Type != models.NotificationReport && notification.Type != models.NotificationRejected {
+				fmt.Fprintf(&body, "%s\n\n", mailExcerpt(post.Content))
+			}
+			fmt.Fprintf(&body, "%s\n\n", AbsoluteURL(fmt.Sprintf("/notifications/%d", notification.ID)))
+		}
+
+		subject := describeNotification(notifications[0])
+		if len(notifications) > 1 {
+			subject = fmt.Sprintf("%d new notifications", len(notifications))
+		}
+
+		// Only once the email is queued are they marked as sent, so if
+		// queueing fails they're tried again next time
+		if err := queueUserMail(user, subject, body.String(), UnsubscribeNotifications); err != nil {
+			fmt.Printf("[error] Could not queue notification email (%s)\n", err.Error())
+		} else if err := models.MarkNotificationsEmailed(notifications); err != nil {
+			fmt.Printf("[error] Could not mark notifications as emailed (%s)\n", err.Error())
+		}
+	}
+}
+
+// Emails a digest to everyone whose digest is due. Users with nothing new
+// in their watched boards are skipped until the next one.
+func queueDigests() {
+	users, err := models.GetUsersDueDigest()
+	if err != nil {
+		fmt.Printf("[error] Could not get users due a digest (%s)\n", err.Error())
+		return
+	}
+
+	for _, user := range users {
+		threads, err := user.GetDigestThreads()
+		if err != nil {
+			fmt.Printf("[error] Could not get digest threads (%s)\n", err.Error())
+			continue
+		}
+
+		if len(threads) > 0 {
+			var body bytes.Buffer
+			fmt.Fprintf(&body, "Here's what's new in the boards you're watching:\n\n")
+
+			boards := map[int64]*models.Board{}
+			for _, thread := range threads {
+				board, ok := boards[thread.BoardID]
+				if !ok {
+					board, _ = models.GetBoard(int(thread.BoardID))
+					boards[thread.BoardID] = board
+				}
+
+				boardTitle := ""
+				if board != nil {
+					boardTitle = board.Title
+				}
+
+				fmt.Fprintf(&body, "%s (in %s), last active %s\n", thread.Title, boardTitle, TimeRelativeToNow(thread.LatestReply))
+				fmt.Fprintf(&body, "%s\n\n", AbsoluteURL(fmt.Sprintf("/board/%d/%d", thread.BoardID, thread.ID)))
+			}
+
+			subject := "Your daily digest"
+			if user.EmailDigest == models.DigestWeekly {
+				subject = "Your weekly digest"
+			}
+
+			if err := queueUserMail(user, subject, body.String(), UnsubscribeDigest); err != nil {
+				fmt.Printf("[error] Could not queue digest (%s)\n", err.Error())
+				continue
+			}
+		}
+
+		if err := user.MarkDigestSent(); err != nil {
+			fmt.Printf("[error] Could not record digest (%s)\n", err.Error())
+		}
+	}
+}
+
+// Sends every queued email that's due, returning how many there were
+func sendDueMail() int {
+	emails, err := models.GetDueEmails()
+	if err != nil {
+		fmt.Printf("[error] Could not get due emails (%s)\n", err.Error())
+		return 0
+	}
+
+	for _, email := range emails {
+		if err := email.RecordAttempt(sendMail(email)); err != nil {
+			fmt.Printf("[error] Could not save email %d (%s)\n", email.ID, err.Error())
+		}
+	}
+
+	return len(emails)
+}
+
+// Starts turning notifications and digests into emails and sending them in
+// the background. Does nothing unless email is set up.
+func StartMailWorker() {
+	if !EmailEnabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		for {
+			queueNotificationEmails()
+			queueDigests()
+
+			// Keep going while there's a backlog
+			for {
+				if sendDueMail() < models.EmailBatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-mailWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
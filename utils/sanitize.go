@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+	"github.com/stevenleeg/gobb/config"
+	"golang.org/x/net/html"
+)
+
+// The defaults cover everything blackfriday's basic renderer produces.
+// Each can be overridden in the [sanitizer] section of the config file.
+const (
+	defaultAllowedTags       = "p,br,hr,h1,h2,h3,h4,h5,h6,blockquote,pre,code,em,strong,del,ul,ol,li,a,img,sup,sub"
+	defaultAllowedAttributes = "a:href,title img:src,alt,title"
+	defaultAllowedSchemes    = "http,https,mailto"
+)
+
+var (
+	sanitizerPolicy     *bluemonday.Policy
+	sanitizerPolicyOnce sync.Once
+)
+
+func getSanitizerOption(name, fallback string) string {
+	value, err := config.Config.GetString("sanitizer", name)
+	if err != nil || strings.TrimSpace(value) == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func splitList(in string) []string {
+	var out []string
+	for _, item := range strings.Split(in, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, strings.ToLower(item))
+		}
+	}
+
+	return out
+}
+
+// Returns the URL schemes users are allowed to link to, both in posts and
+// in URL fields such as their avatar.
+func GetAllowedURLSchemes() []string {
+	return splitList(getSanitizerOption("allowed_schemes", defaultAllowedSchemes))
+}
+
+// Builds the allow-list policy from the config. Anything not explicitly
+// allowed (tags, attributes, URL schemes) is stripped.
+func buildSanitizerPolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements(splitList(getSanitizerOption("allowed_tags", defaultAllowedTags))...)
+
+	// Attributes are given per element as "element:attr,attr element:attr"
+	attributes := getSanitizerOption("allowed_attributes", defaultAllowedAttributes)
+	for _, group := range strings.Fields(attributes) {
+		parts := strings.SplitN(group, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		attrs := splitList(parts[1])
+		for _, attr := range attrs {
+			// Event handlers and inline styles are never allowed, no matter
+			// what the config says.
+			if strings.HasPrefix(attr, "on") || attr == "style" {
+				continue
+			}
+			policy.AllowAttrs(attr).OnElements(strings.ToLower(parts[0]))
+		}
+	}
+
+	policy.AllowURLSchemes(GetAllowedURLSchemes()...)
+	policy.AllowRelativeURLs(true)
+	policy.RequireParseableURLs(true)
+	policy.RequireNoFollowOnLinks(true)
+
+	return policy
+}
+
+func getSanitizerPolicy() *bluemonday.Policy {
+	sanitizerPolicyOnce.Do(func() {
+		sanitizerPolicy = buildSanitizerPolicy()
+	})
+
+	return sanitizerPolicy
+}
+
+// Strips anything from rendered HTML that isn't on the allow-list
+func SanitizeHTML(in []byte) []byte {
+	return getSanitizerPolicy().SanitizeBytes(in)
+}
+
+// Checks a user supplied URL (avatars, stylesheets) against the same
+// scheme allow-list used for links in posts. Empty and relative URLs are
+// fine.
+func ValidateURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(raw, " \t\r\n\"'<>`") {
+		return errors.New("URL contains invalid characters")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("URL could not be parsed")
+	}
+
+	if u.Scheme == "" {
+		return nil
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	for _, allowed := range GetAllowedURLSchemes() {
+		if scheme == allowed {
+			return nil
+		}
+	}
+
+	return errors.New("URLs starting with " + scheme + ": are not allowed")
+}
+
+// Looks through sanitized HTML for anything that shouldn't have survived:
+// tags or schemes that aren't on the allow-list, event handlers and inline
+// styles. Returns a description of the first problem found.
+func findUnsafeHTML(in []byte) string {
+	allowedTags := map[string]bool{}
+	for _, tag := range splitList(getSanitizerOption("allowed_tags", defaultAllowedTags)) {
+		allowedTags[tag] = true
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(in))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if !allowedTags[token.Data] {
+				return "disallowed tag <" + token.Data + ">"
+			}
+
+			for _, attr := range token.Attr {
+				key := strings.ToLower(attr.Key)
+				if strings.HasPrefix(key, "on") || key == "style" {
+					return "disallowed attribute " + key + " on <" + token.Data + ">"
+				}
+
+				if key == "href" || key == "src" {
+					if err := ValidateURL(attr.Val); err != nil {
+						return err.Error()
+					}
+				}
+			}
+		}
+	}
+}
+
+// Runs every payload in the corpus file through the same Markdown and
+// sanitizer pipeline posts go through and reports the ones which still
+// produce unsafe HTML. Payloads are separated by lines containing only
+// "%%"; lines starting with "#" are comments.
+func CheckSanitizerCorpus(path string) (failures []string, total int, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var payloads []string
+	var current []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.TrimSpace(line) == "%%" {
+			payloads = append(payloads, strings.Join(current, "\n"))
+			current = nil
+			continue
+		}
+
+		current = append(current, line)
+	}
+	payloads = append(payloads, strings.Join(current, "\n"))
+
+	for _, payload := range payloads {
+		if strings.TrimSpace(payload) == "" {
+			continue
+		}
+		total++
+
+		out := SanitizeHTML(blackfriday.MarkdownBasic([]byte(payload)))
+		if problem := findUnsafeHTML(out); problem != "" {
+			failures = append(failures, fmt.Sprintf("%s\n    payload: %q\n    output:  %q", problem, payload, out))
+		}
+	}
+
+	return failures, total, nil
+}
+
+// Location of the XSS regression corpus which ships with gobb
+func GetSanitizerCorpusPath() string {
+	pkg, _ := build.Import("github.com/stevenleeg/gobb/gobb", ".", build.FindOnly)
+	return filepath.Join(pkg.SrcRoot, pkg.ImportPath, "../utils/xss_corpus.txt")
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/msbranco/goconfig"
+	"github.com/stevenleeg/gobb/config"
+)
+
+// The tests use the default sanitizer policy
+func init() {
+	if config.Config == nil {
+		config.Config = goconfig.NewConfigFile()
+	}
+}
+
+func TestSanitizerCorpus(t *testing.T) {
+	failures, total, err := CheckSanitizerCorpus("xss_corpus.txt")
+	if err != nil {
+		t.Fatalf("Could not read the corpus: %s", err.Error())
+	}
+	if total == 0 {
+		t.Fatal("The corpus has no payloads")
+	}
+
+	for _, failure := range failures {
+		t.Errorf("Unsafe output: %s", failure)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"", true},
+		{"/static/avatar.png", true},
+		{"https://example.com/avatar.png", true},
+		{"HTTP://example.com/avatar.png", true},
+		{"javascript:alert(1)", false},
+		{"JaVaScRiPt:alert(1)", false},
+		{"data:text/html;base64,PHNjcmlwdD4=", false},
+		{"https://example.com/\" onerror=\"alert(1)", false},
+	}
+
+	for _, test := range tests {
+		if err := ValidateURL(test.url); (err == nil) != test.ok {
+			t.Errorf("ValidateURL(%q) = %v, want ok=%v", test.url, err, test.ok)
+		}
+	}
+}
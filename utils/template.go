@@ -38,9 +38,13 @@ func tplAdd(first, second int) int {
 	return first + second
 }
 
+// Renders a post's Markdown into sanitized HTML
+func RenderMarkdown(input string) string {
+	return string(SanitizeHTML(blackfriday.MarkdownBasic([]byte(input))))
+}
+
 func tplParseMarkdown(input string) template.HTML {
-	byteSlice := []byte(input)
-	return template.HTML(string(blackfriday.MarkdownBasic(byteSlice)))
+	return template.HTML(RenderMarkdown(input))
 }
 
 func tplGetCurrentUser(r *http.Request) func() *models.User {
@@ -73,6 +77,41 @@ var defaultFuncmap = template.FuncMap{
 	"IsValidTime":       tplIsValidTime,
 	"GetStringSetting":  tplGetStringSetting,
 	"ParseFaviconType":  tplParseFaviconType,
+	"DescribeUserAgent": DescribeUserAgent,
+}
+
+// Returns a copy of the default template functions along with the ones
+// bound to this request and its user. The defaults are shared, so request helpers must
+// never be added to them: concurrent renders would write to the same map
+// and could show one user's CSRF token or permissions to another.
+func newRequestFuncmap(r *http.Request, currentUser *models.User) template.FuncMap {
+	funcMap := template.FuncMap{}
+	for key, val := range defaultFuncmap {
+		funcMap[key] = val
+	}
+
+	funcMap["GetCurrentUser"] = tplGetCurrentUser(r)
+	funcMap["CSRFField"] = tplCSRFField(r)
+	funcMap["CurrentUserCan"] = func(permission string) bool {
+		return models.Can(currentUser, permission, nil)
+	}
+
+	return funcMap
+}
+
+// Parses a page along with the base template it fills in. The page can
+// use the default functions, the ones bound to the request, and any funcs
+// of its own, which replace defaults with the same name.
+func ParseTemplate(r *http.Request, basePath string, tplFile string, funcs template.FuncMap) (*template.Template, error) {
+	funcMap := newRequestFuncmap(r, GetCurrentUser(r))
+	for key, val := range funcs {
+		funcMap[key] = val
+	}
+
+	baseTpl := filepath.Join(basePath, "base.html")
+	rendTpl := filepath.Join(basePath, tplFile)
+
+	return template.New("tpl").Funcs(funcMap).ParseFiles(baseTpl, rendTpl)
 }
 
 func RenderTemplate(
@@ -89,15 +128,9 @@ func RenderTemplate(
 	gaAccount, _ := config.Config.GetString("googleanalytics", "account")
 
 	stylesheet := ""
-<<<<<<< Updated upstream
-	if (currentUser != nil) && currentUser.StylesheetUrl.Valid && currentUser.StylesheetUrl.String != "" {
-		stylesheet = currentUser.StylesheetUrl.String
-	} else if currentUser == nil || !currentUser.StylesheetUrl.Valid || currentUser.StylesheetUrl.String == "" {
-=======
 	if (currentUser != nil) && currentUser.StylesheetURL.Valid && currentUser.StylesheetURL.String != "" {
 		stylesheet = currentUser.StylesheetURL.String
 	} else if currentUser == nil || !currentUser.StylesheetURL.Valid || currentUser.StylesheetURL.String == "" {
->>>>>>> Stashed changes
 		globalTheme, _ := models.GetStringSetting("theme_stylesheet")
 		if globalTheme != "" {
 			stylesheet = globalTheme
@@ -122,13 +155,6 @@ func RenderTemplate(
 		send[key] = val
 	}
 
-	// Same with the function map
-	funcMap := defaultFuncmap
-	funcMap["GetCurrentUser"] = tplGetCurrentUser(r)
-	for key, val := range funcs {
-		funcMap[key] = val
-	}
-
 	// Get the base template path
 	selectedTemplate, _ := models.GetStringSetting("template")
 	var basePath string
@@ -140,12 +166,11 @@ func RenderTemplate(
 		basePath = filepath.Join(basePath, "templates", selectedTemplate)
 	}
 
-	baseTpl := filepath.Join(base_path, "base.html")
-	rendTpl := filepath.Join(base_path, tplFile)
-
-	tpl, err := template.New("tpl").Funcs(funcMap).ParseFiles(baseTpl, rendTpl)
+	tpl, err := ParseTemplate(r, basePath, tplFile, funcs)
 	if err != nil {
 		fmt.Printf("[error] Could not parse template (%s)\n", err.Error())
+		out.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	// Attempt to execute the template we're on
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stevenleeg/gobb/models"
+)
+
+func TestRequestFuncmapLeavesDefaultsAlone(t *testing.T) {
+	defaults := len(defaultFuncmap)
+
+	admin := newRequestFuncmap(httptest.NewRequest("GET", "/", nil), &models.User{ID: 1})
+	guest := newRequestFuncmap(httptest.NewRequest("GET", "/", nil), nil)
+
+	if len(defaultFuncmap) != defaults {
+		t.Fatalf("default FuncMap grew from %d to %d entries", defaults, len(defaultFuncmap))
+	}
+
+	for _, name := range []string{"GetCurrentUser", "CSRFField", "CurrentUserCan"} {
+		if _, ok := defaultFuncmap[name]; ok {
+			t.Errorf("%s was added to the shared FuncMap", name)
+		}
+		if _, ok := admin[name]; !ok {
+			t.Errorf("%s is missing from the request FuncMap", name)
+		}
+	}
+
+	admin["OnlyInAdmin"] = func() bool { return true }
+	if _, ok := guest["OnlyInAdmin"]; ok {
+		t.Error("request FuncMaps share their entries")
+	}
+
+	can := guest["CurrentUserCan"].(func(string) bool)
+	if can(models.PermSettingsEdit) {
+		t.Error("guest's CurrentUserCan allowed settings.edit")
+	}
+}
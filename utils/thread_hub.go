@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"sync"
+)
+
+// Kinds of thing that can happen to a post while people are reading its
+// thread
+const (
+	ThreadEventReply = "reply"
+	ThreadEventEdit  = "edit"
+)
+
+// Something that changed in a thread. Subscribers are only told which
+// post changed and where it lives; they load the post themselves, so it
+// is rendered with their own permissions.
+type ThreadEvent struct {
+	Type   string `json:"type"`
+	PostID int64  `json:"post_id"`
+	Page   int    `json:"page"`
+}
+
+// How many events a slow subscriber can fall behind by before it starts
+// missing them
+const threadEventBuffer = 16
+
+// An in-process pub/sub hub keyed by thread ID. It only knows about
+// readers connected to this process.
+var threadHub = struct {
+	sync.Mutex
+	subscribers map[int64]map[chan *ThreadEvent]bool
+}{subscribers: map[int64]map[chan *ThreadEvent]bool{}}
+
+// Starts listening for events in a thread. The returned function must be
+// called once the subscriber goes away.
+func SubscribeToThread(threadID int64) (<-chan *ThreadEvent, func()) {
+	ch := make(chan *ThreadEvent, threadEventBuffer)
+
+	threadHub.Lock()
+	if threadHub.subscribers[threadID] == nil {
+		threadHub.subscribers[threadID] = map[chan *ThreadEvent]bool{}
+	}
+	threadHub.subscribers[threadID][ch] = true
+	threadHub.Unlock()
+
+	return ch, func() {
+		threadHub.Lock()
+		delete(threadHub.subscribers[threadID], ch)
+		if len(threadHub.subscribers[threadID]) == 0 {
+			delete(threadHub.subscribers, threadID)
+		}
+		threadHub.Unlock()
+	}
+}
+
+// Tells everyone reading the thread about the event. Never blocks; a
+// subscriber whose buffer is full simply misses it.
+func PublishThreadEvent(threadID int64, event *ThreadEvent) {
+	threadHub.Lock()
+	defer threadHub.Unlock()
+
+	for ch := range threadHub.subscribers[threadID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"strings"
+)
+
+var knownBrowsers = []struct{ token, name string }{
+	// Order matters: Edge and Opera also claim to be Chrome, which in
+	// turn claims to be Safari.
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+var knownPlatforms = []struct{ token, name string }{
+	{"Android", "Android"},
+	{"iPhone", "iPhone"},
+	{"iPad", "iPad"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "Chrome OS"},
+	{"Linux", "Linux"},
+}
+
+// Turns a User-Agent header into something a person can read, such as
+// "Firefox on Linux". Falls back to the raw string if we can't tell.
+func DescribeUserAgent(ua string) string {
+	browser := ""
+	for _, b := range knownBrowsers {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, p := range knownPlatforms {
+		if strings.Contains(ua, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	if browser == "" && platform == "" {
+		if ua == "" {
+			return "Unknown device"
+		}
+		return ua
+	} else if browser == "" {
+		return platform
+	} else if platform == "" {
+		return browser
+	}
+
+	return browser + " on " + platform
+}